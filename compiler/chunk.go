@@ -2,17 +2,29 @@ package compiler
 
 import "math"
 
+type LocalInfo struct {
+	Name  string
+	Slot  int
+	Start int
+	End   int
+}
+
 type Chunk struct {
-	Code      []byte
-	Constants []Value
+	Code        []byte
+	Constants   []Value
+	Lines       []int
+	LineOffsets map[int][]int
+	Locals      []LocalInfo
+	Tables      []*JumpTable
 }
 
-func (c *Chunk) write(b byte) {
+func (c *Chunk) write(b byte, line int) {
 	c.Code = append(c.Code, b)
+	c.Lines = append(c.Lines, line)
 }
 
-func (c *Chunk) writeOperation(o Operation) {
-	c.write(byte(o))
+func (c *Chunk) writeOperation(o Operation, line int) {
+	c.write(byte(o), line)
 }
 
 func (c *Chunk) writeConstant(v Value) (uint8, bool) {
@@ -22,3 +34,42 @@ func (c *Chunk) writeConstant(v Value) (uint8, bool) {
 	c.Constants = append(c.Constants, v)
 	return uint8(len(c.Constants) - 1), true
 }
+
+func (c *Chunk) writeTable(t *JumpTable) (uint8, bool) {
+	if len(c.Tables) > math.MaxUint8 {
+		return 0, false
+	}
+	c.Tables = append(c.Tables, t)
+	return uint8(len(c.Tables) - 1), true
+}
+
+func (c *Chunk) beginStatement(line int) {
+	offset := len(c.Code)
+	offsets := c.LineOffsets[line]
+	if len(offsets) > 0 && offsets[len(offsets)-1] == offset {
+		return
+	}
+	c.LineOffsets[line] = append(offsets, offset)
+}
+
+func (c *Chunk) Statements() map[int]int {
+	statements := make(map[int]int)
+	for line, offsets := range c.LineOffsets {
+		for _, offset := range offsets {
+			if line > statements[offset] {
+				statements[offset] = line
+			}
+		}
+	}
+	return statements
+}
+
+func (c *Chunk) LocalsAt(offset int) []LocalInfo {
+	locals := make([]LocalInfo, 0)
+	for _, l := range c.Locals {
+		if l.Start <= offset && offset < l.End {
+			locals = append(locals, l)
+		}
+	}
+	return locals
+}
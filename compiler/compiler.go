@@ -1,33 +1,80 @@
 package compiler
 
 import (
+	"errors"
 	"fmt"
 	"math"
-	"strconv"
 
 	"github.com/lukibw/abc/scanner"
 )
 
 type Compiler interface {
 	Run() (*Chunk, error)
+	// Errors returns every error found by Run, which stops at the first
+	// one but keeps compiling from the next statement to find the others.
+	Errors() []error
+	Warnings() []*Warning
+	Symbols() []*Symbol
 }
 
 func New(s scanner.Scanner) Compiler {
-	return &compiler{s, nil, nil, make([]local, 0), 0, nil}
+	return &compiler{
+		scanner:     s,
+		locals:      make([]local, 0),
+		warnings:    make([]*Warning, 0),
+		globals:     make(map[string]bool),
+		globalReads: make([]*scanner.Token, 0),
+		symbols:     make([]*Symbol, 0),
+		globalNames: make(map[string]*Symbol),
+	}
 }
 
 type local struct {
-	name  *scanner.Token
+	name   *scanner.Token
+	depth  int
+	used   bool
+	symbol *Symbol
+	info   int
+	// slot is the position of the value on the stack, it differs from the
+	// position in locals for the locals of a match expression, which are
+	// pushed above the temporaries of the enclosing expression.
+	slot int
+}
+
+// loop describes an enclosing loop for the 'break' and 'continue' statements
+// inside it.
+type loop struct {
+	label *scanner.Token
+	// start is the offset 'continue' jumps back to.
+	start int
+	// depth is the scope depth outside the body, locals deeper than it are
+	// popped when jumping out of the body.
 	depth int
+	// breaks are the offsets of the jumps to patch at the end of the loop.
+	breaks []int
 }
 
 type compiler struct {
-	scanner    scanner.Scanner
-	previous   *scanner.Token
-	current    *scanner.Token
-	locals     []local
-	scopeDepth int
-	chunk      *Chunk
+	scanner     scanner.Scanner
+	previous    *scanner.Token
+	current     *scanner.Token
+	next        *scanner.Token
+	loops       []*loop
+	locals      []local
+	scopeDepth  int
+	chunk       *Chunk
+	warnings    []*Warning
+	globals     map[string]bool
+	globalReads []*scanner.Token
+	symbols     []*Symbol
+	globalNames map[string]*Symbol
+	// localDeclarations are the locals not shadowing anything when they were
+	// declared, they may still shadow a global declared further on.
+	localDeclarations []*scanner.Token
+	errors            []error
+	// height is the number of values on the stack at the end of the code
+	// emitted so far, it is reset at the start of every statement.
+	height int
 }
 
 func (c *compiler) makeConstant(v Value) (uint8, error) {
@@ -43,13 +90,84 @@ func (c *compiler) emitConstant(v Value) error {
 	if err != nil {
 		return err
 	}
-	c.chunk.writeOperation(OperationConstant)
-	c.chunk.write(i)
+	c.emitOperation(OperationConstant)
+	c.emitByte(i)
 	return nil
 }
 
+func (c *compiler) warn(k WarningKind, t *scanner.Token) {
+	c.warnings = append(c.warnings, &Warning{k, t})
+}
+
+func (c *compiler) addSymbol(name string, global bool, declaration *scanner.Token) *Symbol {
+	s := newSymbol(name, global, declaration)
+	c.symbols = append(c.symbols, s)
+	return s
+}
+
+func (c *compiler) globalSymbol(name string) *Symbol {
+	s, ok := c.globalNames[name]
+	if !ok {
+		s = c.addSymbol(name, true, nil)
+		c.globalNames[name] = s
+	}
+	return s
+}
+
+// pureOperations are the operations whose result depends only on their
+// operands, an expression made of them alone has the same value every time.
+var pureOperations = map[Operation]bool{
+	OperationConstant:     true,
+	OperationNil:          true,
+	OperationFalse:        true,
+	OperationTrue:         true,
+	OperationNot:          true,
+	OperationNegate:       true,
+	OperationAdd:          true,
+	OperationSubtract:     true,
+	OperationMultiply:     true,
+	OperationDivide:       true,
+	OperationFloorDivide:  true,
+	OperationModulo:       true,
+	OperationPower:        true,
+	OperationEqual:        true,
+	OperationGreater:      true,
+	OperationLess:         true,
+	OperationBitAnd:       true,
+	OperationBitOr:        true,
+	OperationBitXor:       true,
+	OperationBitNot:       true,
+	OperationShiftLeft:    true,
+	OperationShiftRight:   true,
+	OperationStringify:    true,
+	OperationPop:          true,
+	OperationJump:         true,
+	OperationJumpIfFalse:  true,
+	OperationJumpIfNotNil: true,
+}
+
+// isConstant reports whether the code emitted since start computes the same
+// value every time, as for "true", "!true" or "1 < 2".
+func (c *compiler) isConstant(start int) bool {
+	code := c.chunk.Code[start:]
+	if len(code) == 0 {
+		return false
+	}
+	for i := 0; i < len(code); i += Operation(code[i]).Size() {
+		if !pureOperations[Operation(code[i])] {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *compiler) emitByte(b byte) {
+	c.chunk.write(b, c.previous.Line)
+}
+
 func (c *compiler) emitOperation(o Operation) {
-	c.chunk.writeOperation(o)
+	c.chunk.writeOperation(o, c.previous.Line)
+	c.height += stackEffects[o]
 }
 
 func (c *compiler) emitOperations(o1, o2 Operation) {
@@ -63,15 +181,15 @@ func (c *compiler) emitLoop(start int) error {
 	if offset > math.MaxUint16 {
 		return &Error{ErrTooBigLoop, c.previous}
 	}
-	c.chunk.write(byte((offset >> 8) & 0xff))
-	c.chunk.write(byte(offset & 0xff))
+	c.emitByte(byte((offset >> 8) & 0xff))
+	c.emitByte(byte(offset & 0xff))
 	return nil
 }
 
 func (c *compiler) emitJump(o Operation) int {
 	c.emitOperation(o)
-	c.chunk.write(0xff)
-	c.chunk.write(0xff)
+	c.emitByte(0xff)
+	c.emitByte(0xff)
 	return len(c.chunk.Code) - 2
 }
 
@@ -91,6 +209,10 @@ func (c *compiler) check(k scanner.TokenKind) bool {
 
 func (c *compiler) advance() error {
 	c.previous = c.current
+	if c.next != nil {
+		c.current, c.next = c.next, nil
+		return nil
+	}
 	t, err := c.scanner.Token()
 	if err != nil {
 		return err
@@ -99,6 +221,18 @@ func (c *compiler) advance() error {
 	return nil
 }
 
+// peek returns the token after the current one.
+func (c *compiler) peek() (*scanner.Token, error) {
+	if c.next == nil {
+		t, err := c.scanner.Token()
+		if err != nil {
+			return nil, err
+		}
+		c.next = t
+	}
+	return c.next, nil
+}
+
 func (c *compiler) consume(t scanner.TokenKind, e ErrorKind) error {
 	if c.current.Kind == t {
 		return c.advance()
@@ -111,28 +245,96 @@ func (c *compiler) beginScope() {
 }
 
 func (c *compiler) endScope() {
+	c.leaveScope(true)
+}
+
+// leaveScope removes the locals of the innermost scope, popping them from the
+// stack if pop is set.
+func (c *compiler) leaveScope(pop bool) {
 	c.scopeDepth--
 	for len(c.locals) > 0 && c.locals[len(c.locals)-1].depth > c.scopeDepth {
-		c.emitOperation(OperationPop)
+		l := c.locals[len(c.locals)-1]
+		if !l.used {
+			c.warn(WarnUnusedLocal, l.name)
+		}
+		c.chunk.Locals[l.info].End = len(c.chunk.Code)
+		if pop {
+			c.emitOperation(OperationPop)
+		}
 		c.locals = c.locals[:len(c.locals)-1]
 	}
 }
 
 func (c *compiler) number() error {
-	n, err := strconv.ParseFloat(c.previous.Lexeme, 64)
+	if scanner.IsBigInteger(c.previous.Lexeme) {
+		return c.emitConstant(NewBigInteger(scanner.ParseBigInteger(c.previous.Lexeme)))
+	}
+	if scanner.IsInteger(c.previous.Lexeme) {
+		n, err := scanner.ParseInteger(c.previous.Lexeme)
+		if err != nil {
+			return &Error{ErrNumberRange, c.previous}
+		}
+		return c.emitConstant(NewInteger(n))
+	}
+	n, err := scanner.ParseFloat(c.previous.Lexeme)
 	if err != nil {
-		panic("compiler: cannot parse float from token lexeme")
+		return &Error{ErrNumberRange, c.previous}
 	}
 	return c.emitConstant(NewNumber(n))
 }
 
 func (c *compiler) string() error {
-	return c.emitConstant(NewString(c.previous.Lexeme[1 : len(c.previous.Lexeme)-1]))
+	return c.emitConstant(NewString(scanner.Unquote(c.previous.Lexeme)))
+}
+
+// interpolation compiles a string with embedded expressions, each of them is
+// converted to a string and concatenated with the literal parts around it.
+func (c *compiler) interpolation() error {
+	if err := c.string(); err != nil {
+		return err
+	}
+	for {
+		if err := c.expression(); err != nil {
+			return err
+		}
+		c.emitOperations(OperationStringify, OperationAdd)
+		if !c.check(scanner.TokenInterpolation) {
+			break
+		}
+		if err := c.advance(); err != nil {
+			return err
+		}
+		if err := c.stringPart(); err != nil {
+			return err
+		}
+	}
+	if err := c.consume(scanner.TokenString, ErrMissingInterpolationEnd); err != nil {
+		return err
+	}
+	return c.stringPart()
+}
+
+func (c *compiler) stringPart() error {
+	s := scanner.Unquote(c.previous.Lexeme)
+	if s == "" {
+		return nil
+	}
+	if err := c.emitConstant(NewString(s)); err != nil {
+		return err
+	}
+	c.emitOperation(OperationAdd)
+	return nil
 }
 
 func (c *compiler) binary() error {
 	operator := c.previous.Kind
-	if err := c.parsePrecedence(parseRules[operator].precedence + 1); err != nil {
+	// The exponent is right-associative, its right operand may contain
+	// another exponent.
+	right := parseRules[operator].precedence + 1
+	if operator == scanner.TokenStarStar {
+		right = precedenceExponent
+	}
+	if err := c.parsePrecedence(right); err != nil {
 		return err
 	}
 	switch operator {
@@ -144,6 +346,22 @@ func (c *compiler) binary() error {
 		c.emitOperation(OperationMultiply)
 	case scanner.TokenSlash:
 		c.emitOperation(OperationDivide)
+	case scanner.TokenTildeSlash:
+		c.emitOperation(OperationFloorDivide)
+	case scanner.TokenPercent:
+		c.emitOperation(OperationModulo)
+	case scanner.TokenStarStar:
+		c.emitOperation(OperationPower)
+	case scanner.TokenAmpersand:
+		c.emitOperation(OperationBitAnd)
+	case scanner.TokenPipe:
+		c.emitOperation(OperationBitOr)
+	case scanner.TokenCaret:
+		c.emitOperation(OperationBitXor)
+	case scanner.TokenLessLess:
+		c.emitOperation(OperationShiftLeft)
+	case scanner.TokenGreaterGreater:
+		c.emitOperation(OperationShiftRight)
 	case scanner.TokenBangEqual:
 		c.emitOperations(OperationEqual, OperationNot)
 	case scanner.TokenEqualEqual:
@@ -193,6 +411,8 @@ func (c *compiler) unary() error {
 		c.emitOperation(OperationNegate)
 	case scanner.TokenBang:
 		c.emitOperation(OperationNot)
+	case scanner.TokenTilde:
+		c.emitOperation(OperationBitNot)
 	default:
 		panic(fmt.Sprintf("compiler: unexpected token kind '%s' for unary expression", operator))
 	}
@@ -211,37 +431,126 @@ func (c *compiler) resolveLocal(t *scanner.Token) (int, error) {
 	return -1, nil
 }
 
-func (c *compiler) namedVariable(t *scanner.Token, canAssign bool) error {
-	var getOp, setOp Operation
+// resolveVariable returns the operations reading and writing the variable
+// named by t together with their operand.
+func (c *compiler) resolveVariable(t *scanner.Token) (Operation, Operation, uint8, error) {
 	i, err := c.resolveLocal(t)
 	if err != nil {
-		return err
+		return 0, 0, 0, err
 	}
 	if i != -1 {
-		getOp = OperationGetLocal
-		setOp = OperationSetLocal
+		c.locals[i].symbol.reference(t)
+		return OperationGetLocal, OperationSetLocal, uint8(c.locals[i].slot), nil
+	}
+	c.globalSymbol(t.Lexeme).reference(t)
+	x, err := c.identifierConstant(t)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	return OperationGetGlobal, OperationSetGlobal, x, nil
+}
+
+// emitRead reads the variable named by t, marking it as used.
+func (c *compiler) emitRead(t *scanner.Token, getOp Operation, arg uint8) {
+	if getOp == OperationGetLocal {
+		for i := len(c.locals) - 1; i >= 0; i-- {
+			if c.locals[i].slot == int(arg) {
+				c.locals[i].used = true
+				break
+			}
+		}
 	} else {
-		x, err := c.identifierConstant(t)
-		if err != nil {
+		c.globalReads = append(c.globalReads, t)
+	}
+	c.emitOperation(getOp)
+	c.emitByte(arg)
+}
+
+var compoundOperations = map[scanner.TokenKind]Operation{
+	scanner.TokenPlusEqual:  OperationAdd,
+	scanner.TokenMinusEqual: OperationSubtract,
+	scanner.TokenStarEqual:  OperationMultiply,
+	scanner.TokenSlashEqual: OperationDivide,
+}
+
+// emitIncrement adds one to or subtracts one from the value on top of the
+// stack, depending on the kind of the '++' or '--' token.
+func (c *compiler) emitIncrement(k scanner.TokenKind) error {
+	if err := c.emitConstant(NewInteger(1)); err != nil {
+		return err
+	}
+	if k == scanner.TokenPlusPlus {
+		c.emitOperation(OperationAdd)
+	} else {
+		c.emitOperation(OperationSubtract)
+	}
+	return nil
+}
+
+func (c *compiler) namedVariable(t *scanner.Token, canAssign bool) error {
+	getOp, setOp, arg, err := c.resolveVariable(t)
+	if err != nil {
+		return err
+	}
+	operation, compound := compoundOperations[c.current.Kind]
+	switch {
+	case canAssign && c.check(scanner.TokenEqual):
+		if err = c.advance(); err != nil {
 			return err
 		}
-		i = int(x)
-		getOp = OperationGetGlobal
-		setOp = OperationSetGlobal
-	}
-	if canAssign && c.check(scanner.TokenEqual) {
+		if err = c.expression(); err != nil {
+			return err
+		}
+	case canAssign && compound:
 		if err = c.advance(); err != nil {
 			return err
 		}
+		c.emitRead(t, getOp, arg)
 		if err = c.expression(); err != nil {
 			return err
 		}
+		c.emitOperation(operation)
+	case c.check(scanner.TokenPlusPlus) || c.check(scanner.TokenMinusMinus):
+		// The old value stays on the stack below the updated one, which
+		// is popped after being stored.
+		if err = c.advance(); err != nil {
+			return err
+		}
+		c.emitRead(t, getOp, arg)
+		c.emitRead(t, getOp, arg)
+		if err = c.emitIncrement(c.previous.Kind); err != nil {
+			return err
+		}
 		c.emitOperation(setOp)
-		c.chunk.write(uint8(i))
-	} else {
-		c.emitOperation(getOp)
-		c.chunk.write(uint8(i))
+		c.emitByte(arg)
+		c.emitOperation(OperationPop)
+		return nil
+	default:
+		c.emitRead(t, getOp, arg)
+		return nil
 	}
+	c.emitOperation(setOp)
+	c.emitByte(arg)
+	return nil
+}
+
+// increment compiles a prefix '++' or '--', whose value is the updated one.
+func (c *compiler) increment() error {
+	operator := c.previous.Kind
+	if err := c.consume(scanner.TokenIdentifier, ErrInvalidIncrementTarget); err != nil {
+		return err
+	}
+	t := c.previous
+	getOp, setOp, arg, err := c.resolveVariable(t)
+	if err != nil {
+		return err
+	}
+	c.emitRead(t, getOp, arg)
+	if err = c.emitIncrement(operator); err != nil {
+		return err
+	}
+	c.emitOperation(setOp)
+	c.emitByte(arg)
 	return nil
 }
 
@@ -272,6 +581,91 @@ func (c *compiler) or() error {
 	return c.patchJump(endJump)
 }
 
+// conditional compiles 'condition ? then : else', evaluating only the chosen
+// branch. The else branch may itself be a conditional.
+func (c *compiler) conditional() error {
+	elseJump := c.emitJump(OperationJumpIfFalse)
+	c.emitOperation(OperationPop)
+	if err := c.expression(); err != nil {
+		return err
+	}
+	if err := c.consume(scanner.TokenColon, ErrMissingConditionalColon); err != nil {
+		return err
+	}
+	endJump := c.emitJump(OperationJump)
+	if err := c.patchJump(elseJump); err != nil {
+		return err
+	}
+	c.emitOperation(OperationPop)
+	if err := c.parsePrecedence(precedenceConditional); err != nil {
+		return err
+	}
+	return c.patchJump(endJump)
+}
+
+// coalesce compiles 'value ?? fallback', which evaluates the fallback only
+// when the value is nil.
+func (c *compiler) coalesce() error {
+	endJump := c.emitJump(OperationJumpIfNotNil)
+	c.emitOperation(OperationPop)
+	if err := c.parsePrecedence(precedenceCoalesce + 1); err != nil {
+		return err
+	}
+	return c.patchJump(endJump)
+}
+
+func (c *compiler) dot() error {
+	if err := c.consume(scanner.TokenIdentifier, ErrMissingPropertyName); err != nil {
+		return err
+	}
+	name, err := c.identifierConstant(c.previous)
+	if err != nil {
+		return err
+	}
+	c.emitOperation(OperationGetProperty)
+	c.emitByte(name)
+	return nil
+}
+
+func (c *compiler) index() error {
+	if err := c.expression(); err != nil {
+		return err
+	}
+	if err := c.consume(scanner.TokenRightBracket, ErrMissingIndexRightBracket); err != nil {
+		return err
+	}
+	c.emitOperation(OperationGetIndex)
+	return nil
+}
+
+func (c *compiler) call() error {
+	argc := 0
+	if !c.check(scanner.TokenRightParen) {
+		for {
+			if err := c.expression(); err != nil {
+				return err
+			}
+			if argc == math.MaxUint8 {
+				return &Error{ErrTooManyArguments, c.previous}
+			}
+			argc++
+			if !c.check(scanner.TokenComma) {
+				break
+			}
+			if err := c.advance(); err != nil {
+				return err
+			}
+		}
+	}
+	if err := c.consume(scanner.TokenRightParen, ErrMissingCallRightParen); err != nil {
+		return err
+	}
+	c.emitOperation(OperationCall)
+	c.emitByte(byte(argc))
+	c.height -= argc
+	return nil
+}
+
 func (c *compiler) parseFunction(f parseFunction, canAssign bool) error {
 	switch f {
 	case parseFunctionBinary:
@@ -284,6 +678,8 @@ func (c *compiler) parseFunction(f parseFunction, canAssign bool) error {
 		return c.number()
 	case parseFunctionString:
 		return c.string()
+	case parseFunctionInterpolation:
+		return c.interpolation()
 	case parseFunctionLiteral:
 		c.literal()
 		return nil
@@ -293,6 +689,20 @@ func (c *compiler) parseFunction(f parseFunction, canAssign bool) error {
 		return c.and()
 	case parseFunctionOr:
 		return c.or()
+	case parseFunctionDot:
+		return c.dot()
+	case parseFunctionIndex:
+		return c.index()
+	case parseFunctionCall:
+		return c.call()
+	case parseFunctionIncrement:
+		return c.increment()
+	case parseFunctionConditional:
+		return c.conditional()
+	case parseFunctionCoalesce:
+		return c.coalesce()
+	case parseFunctionMatch:
+		return c.match()
 	default:
 		return &Error{ErrMissingExpr, c.previous}
 	}
@@ -315,7 +725,7 @@ func (c *compiler) parsePrecedence(min precedence) error {
 			return err
 		}
 	}
-	if canAssign && c.check(scanner.TokenEqual) {
+	if _, compound := compoundOperations[c.current.Kind]; canAssign && (compound || c.check(scanner.TokenEqual)) {
 		if err = c.advance(); err != nil {
 			return err
 		}
@@ -328,6 +738,22 @@ func (c *compiler) expression() error {
 	return c.parsePrecedence(precedenceAssignment)
 }
 
+// condition compiles the condition of an if or while statement and warns
+// when it is constant. A loop on a literal true is left alone, it is the usual
+// way to write a loop that exits with break.
+func (c *compiler) condition(loop bool) error {
+	start := len(c.chunk.Code)
+	t := c.current
+	if err := c.expression(); err != nil {
+		return err
+	}
+	forever := loop && len(c.chunk.Code) == start+1 && Operation(c.chunk.Code[start]) == OperationTrue
+	if c.isConstant(start) && !forever {
+		c.warn(WarnConstantCondition, t)
+	}
+	return nil
+}
+
 func (c *compiler) printStatement() error {
 	var err error
 	if err = c.expression(); err != nil {
@@ -345,7 +771,7 @@ func (c *compiler) ifStatement() error {
 	if err = c.consume(scanner.TokenLeftParen, ErrIfLeftParen); err != nil {
 		return err
 	}
-	if err = c.expression(); err != nil {
+	if err = c.condition(false); err != nil {
 		return err
 	}
 	if err = c.consume(scanner.TokenRightParen, ErrIfRightParen); err != nil {
@@ -372,13 +798,257 @@ func (c *compiler) ifStatement() error {
 	return c.patchJump(elseJump)
 }
 
-func (c *compiler) whileStatement() error {
+// caseValue is the code of a case value, it is compiled where it appears
+// and emitted again in the dispatch after the case bodies.
+type caseValue struct {
+	token *scanner.Token
+	code  []byte
+	lines []int
+}
+
+// constant returns the value of a case value that is a literal.
+func (v caseValue) constant(constants []Value) (Value, bool) {
+	switch {
+	case len(v.code) == 1 && Operation(v.code[0]) == OperationNil:
+		return NewNil(), true
+	case len(v.code) == 1 && Operation(v.code[0]) == OperationFalse:
+		return NewBoolean(false), true
+	case len(v.code) == 1 && Operation(v.code[0]) == OperationTrue:
+		return NewBoolean(true), true
+	case len(v.code) == 2 && Operation(v.code[0]) == OperationConstant:
+		return constants[v.code[1]], true
+	default:
+		return Value{}, false
+	}
+}
+
+type switchCase struct {
+	values []caseValue
+	body   int
+}
+
+// caseValue compiles an expression and cuts its code out of the chunk.
+func (c *compiler) caseValue() (caseValue, error) {
+	token := c.current
+	start := len(c.chunk.Code)
+	if err := c.expression(); err != nil {
+		return caseValue{}, err
+	}
+	v := caseValue{token, append([]byte(nil), c.chunk.Code[start:]...), append([]int(nil), c.chunk.Lines[start:]...)}
+	c.chunk.Code = c.chunk.Code[:start]
+	c.chunk.Lines = c.chunk.Lines[:start]
+	// A negated number is folded into a constant, so that it can be a case
+	// of the jump table.
+	if len(v.code) == 3 && Operation(v.code[0]) == OperationConstant && Operation(v.code[2]) == OperationNegate {
+		if n, ok := Negate(c.chunk.Constants[v.code[1]]); ok {
+			x, err := c.makeConstant(n)
+			if err != nil {
+				return caseValue{}, err
+			}
+			v.code = []byte{byte(OperationConstant), x}
+			v.lines = v.lines[:2]
+		}
+	}
+	return v, nil
+}
+
+// switchStatement compiles the case bodies first, each of them jumping to
+// the end, and then the dispatch which jumps back to the matching body. The
+// subject is evaluated once into a local named by the keyword, which no
+// identifier can refer to.
+func (c *compiler) switchStatement() error {
+	var err error
+	keyword := c.previous
+	if err = c.consume(scanner.TokenLeftParen, ErrSwitchLeftParen); err != nil {
+		return err
+	}
+	c.beginScope()
+	if err = c.expression(); err != nil {
+		return err
+	}
+	if err = c.consume(scanner.TokenRightParen, ErrSwitchRightParen); err != nil {
+		return err
+	}
+	if err = c.consume(scanner.TokenLeftBrace, ErrSwitchLeftBrace); err != nil {
+		return err
+	}
+	if len(c.locals) > math.MaxUint8 {
+		return &Error{ErrTooManyLocals, keyword}
+	}
+	c.locals = append(c.locals, local{name: keyword, used: true, slot: len(c.locals)})
+	c.markInitialized()
+	subject := uint8(len(c.locals) - 1)
+	dispatchJump := c.emitJump(OperationJump)
+	cases := make([]switchCase, 0)
+	defaultBody := -1
+	endJumps := make([]int, 0)
+	seen := make(map[string]bool)
+	for !c.check(scanner.TokenRightBrace) && !c.check(scanner.TokenEof) {
+		switch {
+		case c.check(scanner.TokenCase):
+			if err = c.advance(); err != nil {
+				return err
+			}
+			var sc switchCase
+			// Case values are evaluated in the dispatch, where only the
+			// subject is on the stack above the locals.
+			c.height = len(c.locals)
+			for {
+				v, err := c.caseValue()
+				if err != nil {
+					return err
+				}
+				if value, ok := v.constant(c.chunk.Constants); ok {
+					if k, ok := tableKey(value); ok {
+						if seen[k] {
+							return &Error{ErrDuplicateCase, v.token}
+						}
+						seen[k] = true
+					}
+				}
+				sc.values = append(sc.values, v)
+				if !c.check(scanner.TokenComma) {
+					break
+				}
+				if err = c.advance(); err != nil {
+					return err
+				}
+			}
+			sc.body = len(c.chunk.Code)
+			cases = append(cases, sc)
+		case c.check(scanner.TokenDefault):
+			if defaultBody >= 0 {
+				return &Error{ErrDuplicateDefault, c.current}
+			}
+			if err = c.advance(); err != nil {
+				return err
+			}
+			defaultBody = len(c.chunk.Code)
+		default:
+			return &Error{ErrMissingCase, c.current}
+		}
+		if err = c.consume(scanner.TokenColon, ErrMissingCaseColon); err != nil {
+			return err
+		}
+		c.beginScope()
+		for !c.check(scanner.TokenCase) && !c.check(scanner.TokenDefault) && !c.check(scanner.TokenRightBrace) && !c.check(scanner.TokenEof) {
+			if err = c.declaration(); err != nil {
+				return err
+			}
+		}
+		c.endScope()
+		endJumps = append(endJumps, c.emitJump(OperationJump))
+	}
+	if err = c.consume(scanner.TokenRightBrace, ErrSwitchRightBrace); err != nil {
+		return err
+	}
+	if err = c.patchJump(dispatchJump); err != nil {
+		return err
+	}
+	if err = c.dispatch(subject, cases, defaultBody); err != nil {
+		return err
+	}
+	for _, offset := range endJumps {
+		if err = c.patchJump(offset); err != nil {
+			return err
+		}
+	}
+	c.endScope()
+	return nil
+}
+
+// dispatch emits a SWITCH through a jump table when all case values are
+// literals and compares the subject with each value in turn otherwise.
+func (c *compiler) dispatch(subject uint8, cases []switchCase, defaultBody int) error {
+	table := &JumpTable{Cases: make(map[string]int)}
+	for _, sc := range cases {
+		for _, v := range sc.values {
+			value, ok := v.constant(c.chunk.Constants)
+			if !ok {
+				table = nil
+				break
+			}
+			k, ok := tableKey(value)
+			if !ok {
+				table = nil
+				break
+			}
+			table.Cases[k] = sc.body
+		}
+		if table == nil {
+			break
+		}
+	}
+	if table != nil {
+		i, ok := c.chunk.writeTable(table)
+		if !ok {
+			return &Error{ErrTooManyJumpTables, c.previous}
+		}
+		c.emitOperation(OperationGetLocal)
+		c.emitByte(subject)
+		c.emitOperation(OperationSwitch)
+		c.emitByte(i)
+		end := len(c.chunk.Code)
+		for k, body := range table.Cases {
+			table.Cases[k] = body - end
+		}
+		if defaultBody >= 0 {
+			table.Default = defaultBody - end
+		}
+		return nil
+	}
+	for _, sc := range cases {
+		for _, v := range sc.values {
+			c.chunk.Code = append(c.chunk.Code, v.code...)
+			c.chunk.Lines = append(c.chunk.Lines, v.lines...)
+			c.emitOperation(OperationGetLocal)
+			c.emitByte(subject)
+			c.emitOperation(OperationEqual)
+			skipJump := c.emitJump(OperationJumpIfFalse)
+			c.emitOperation(OperationPop)
+			if err := c.emitLoop(sc.body); err != nil {
+				return err
+			}
+			if err := c.patchJump(skipJump); err != nil {
+				return err
+			}
+			c.emitOperation(OperationPop)
+		}
+	}
+	if defaultBody >= 0 {
+		return c.emitLoop(defaultBody)
+	}
+	return nil
+}
+
+func (c *compiler) beginLoop(label *scanner.Token) *loop {
+	l := &loop{label: label, depth: c.scopeDepth}
+	c.loops = append(c.loops, l)
+	return l
+}
+
+// endLoop patches the breaks of the innermost loop to jump to the current
+// offset.
+func (c *compiler) endLoop() error {
+	l := c.loops[len(c.loops)-1]
+	c.loops = c.loops[:len(c.loops)-1]
+	for _, offset := range l.breaks {
+		if err := c.patchJump(offset); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) whileStatement(label *scanner.Token) error {
 	loopStart := len(c.chunk.Code)
+	l := c.beginLoop(label)
+	l.start = loopStart
 	var err error
 	if err = c.consume(scanner.TokenLeftParen, ErrWhileLeftParen); err != nil {
 		return err
 	}
-	if err = c.expression(); err != nil {
+	if err = c.condition(true); err != nil {
 		return err
 	}
 	if err = c.consume(scanner.TokenRightParen, ErrWhileRightParen); err != nil {
@@ -396,11 +1066,12 @@ func (c *compiler) whileStatement() error {
 		return err
 	}
 	c.emitOperation(OperationPop)
-	return nil
+	return c.endLoop()
 }
 
-func (c *compiler) forStatement() error {
+func (c *compiler) forStatement(label *scanner.Token) error {
 	c.beginScope()
+	l := c.beginLoop(label)
 	var err error
 	if err = c.consume(scanner.TokenLeftParen, ErrForLeftParen); err != nil {
 		return err
@@ -459,6 +1130,7 @@ func (c *compiler) forStatement() error {
 			return err
 		}
 	}
+	l.start = loopStart
 	if err = c.statement(); err != nil {
 		return err
 	}
@@ -471,10 +1143,80 @@ func (c *compiler) forStatement() error {
 		}
 		c.emitOperation(OperationPop)
 	}
+	if err = c.endLoop(); err != nil {
+		return err
+	}
 	c.endScope()
 	return nil
 }
 
+// labeledStatement compiles a loop preceded by 'label:'.
+func (c *compiler) labeledStatement() error {
+	label := c.previous
+	if err := c.advance(); err != nil {
+		return err
+	}
+	for _, l := range c.loops {
+		if l.label != nil && l.label.Lexeme == label.Lexeme {
+			return &Error{ErrLabelAlreadyDefined, label}
+		}
+	}
+	switch {
+	case c.check(scanner.TokenWhile):
+		if err := c.advance(); err != nil {
+			return err
+		}
+		return c.whileStatement(label)
+	case c.check(scanner.TokenFor):
+		if err := c.advance(); err != nil {
+			return err
+		}
+		return c.forStatement(label)
+	default:
+		return &Error{ErrLabelTarget, label}
+	}
+}
+
+// jumpStatement compiles 'break' and 'continue', optionally followed by the
+// label of an enclosing loop. The locals declared inside the loop body are
+// popped before jumping.
+func (c *compiler) jumpStatement() error {
+	keyword := c.previous
+	isBreak := keyword.Kind == scanner.TokenBreak
+	if len(c.loops) == 0 {
+		if isBreak {
+			return &Error{ErrBreakOutsideLoop, keyword}
+		}
+		return &Error{ErrContinueOutsideLoop, keyword}
+	}
+	target := c.loops[len(c.loops)-1]
+	if c.check(scanner.TokenIdentifier) {
+		if err := c.advance(); err != nil {
+			return err
+		}
+		target = nil
+		for _, l := range c.loops {
+			if l.label != nil && l.label.Lexeme == c.previous.Lexeme {
+				target = l
+			}
+		}
+		if target == nil {
+			return &Error{ErrUndefinedLabel, c.previous}
+		}
+	}
+	if err := c.consume(scanner.TokenSemicolon, ErrMissingJumpSemicolon); err != nil {
+		return err
+	}
+	for i := len(c.locals) - 1; i >= 0 && c.locals[i].depth > target.depth; i-- {
+		c.emitOperation(OperationPop)
+	}
+	if isBreak {
+		target.breaks = append(target.breaks, c.emitJump(OperationJump))
+		return nil
+	}
+	return c.emitLoop(target.start)
+}
+
 func (c *compiler) block() error {
 	var err error
 	for !c.check(scanner.TokenRightBrace) && !c.check(scanner.TokenEof) {
@@ -498,6 +1240,8 @@ func (c *compiler) expressionStatement() error {
 }
 
 func (c *compiler) statement() error {
+	c.chunk.beginStatement(c.current.Line)
+	c.height = len(c.locals)
 	switch {
 	case c.check(scanner.TokenPrint):
 		if err := c.advance(); err != nil {
@@ -513,12 +1257,22 @@ func (c *compiler) statement() error {
 		if err := c.advance(); err != nil {
 			return err
 		}
-		return c.whileStatement()
+		return c.whileStatement(nil)
 	case c.check(scanner.TokenFor):
 		if err := c.advance(); err != nil {
 			return err
 		}
-		return c.forStatement()
+		return c.forStatement(nil)
+	case c.check(scanner.TokenSwitch):
+		if err := c.advance(); err != nil {
+			return err
+		}
+		return c.switchStatement()
+	case c.check(scanner.TokenBreak), c.check(scanner.TokenContinue):
+		if err := c.advance(); err != nil {
+			return err
+		}
+		return c.jumpStatement()
 	case c.check(scanner.TokenLeftBrace):
 		if err := c.advance(); err != nil {
 			return err
@@ -530,6 +1284,18 @@ func (c *compiler) statement() error {
 		c.endScope()
 		return nil
 	default:
+		if c.check(scanner.TokenIdentifier) {
+			next, err := c.peek()
+			if err != nil {
+				return err
+			}
+			if next.Kind == scanner.TokenColon {
+				if err := c.advance(); err != nil {
+					return err
+				}
+				return c.labeledStatement()
+			}
+		}
 		return c.expressionStatement()
 	}
 }
@@ -542,7 +1308,7 @@ func (c *compiler) addLocal(name *scanner.Token) error {
 	if len(c.locals) > math.MaxUint8 {
 		return &Error{ErrTooManyLocals, c.previous}
 	}
-	c.locals = append(c.locals, local{name, -1})
+	c.locals = append(c.locals, local{name, -1, false, c.addSymbol(name.Lexeme, false, name), -1, len(c.locals)})
 	return nil
 }
 
@@ -550,13 +1316,21 @@ func (c *compiler) declareVariable() error {
 	if c.scopeDepth == 0 {
 		return nil
 	}
+	shadowed := c.globals[c.previous.Lexeme]
 	for i := len(c.locals) - 1; i >= 0; i-- {
+		if c.previous.Lexeme != c.locals[i].name.Lexeme {
+			continue
+		}
 		if c.locals[i].depth != -1 && c.locals[i].depth < c.scopeDepth {
+			shadowed = true
 			break
 		}
-		if c.previous.Lexeme == c.locals[i].name.Lexeme {
-			return &Error{ErrVarAlreadyDefined, c.previous}
-		}
+		return &Error{ErrVarAlreadyDefined, c.previous}
+	}
+	if shadowed {
+		c.warn(WarnShadowedVar, c.previous)
+	} else {
+		c.localDeclarations = append(c.localDeclarations, c.previous)
 	}
 	return c.addLocal(c.previous)
 }
@@ -572,11 +1346,19 @@ func (c *compiler) parseVariable(k ErrorKind) (uint8, error) {
 	if c.scopeDepth > 0 {
 		return 0, nil
 	}
+	if s := c.globalSymbol(c.previous.Lexeme); s.Declaration == nil {
+		s.Declaration = c.previous
+	} else {
+		s.reference(c.previous)
+	}
 	return c.identifierConstant(c.previous)
 }
 
 func (c *compiler) markInitialized() {
-	c.locals[len(c.locals)-1].depth = c.scopeDepth
+	l := &c.locals[len(c.locals)-1]
+	l.depth = c.scopeDepth
+	l.info = len(c.chunk.Locals)
+	c.chunk.Locals = append(c.chunk.Locals, LocalInfo{l.name.Lexeme, l.slot, len(c.chunk.Code), -1})
 }
 
 func (c *compiler) defineVariable(v uint8) {
@@ -584,8 +1366,9 @@ func (c *compiler) defineVariable(v uint8) {
 		c.markInitialized()
 		return
 	}
+	c.globals[c.chunk.Constants[v].AsString()] = true
 	c.emitOperation(OperationDefineGlobal)
-	c.chunk.write(v)
+	c.emitByte(v)
 }
 
 func (c *compiler) varDeclaration() error {
@@ -611,6 +1394,8 @@ func (c *compiler) varDeclaration() error {
 }
 
 func (c *compiler) declaration() error {
+	c.chunk.beginStatement(c.current.Line)
+	c.height = len(c.locals)
 	if c.check(scanner.TokenVar) {
 		if err := c.advance(); err != nil {
 			return err
@@ -623,7 +1408,7 @@ func (c *compiler) declaration() error {
 func (c *compiler) Run() (*Chunk, error) {
 	if c.chunk == nil {
 		var err error
-		c.chunk = &Chunk{make([]byte, 0), make([]Value, 0)}
+		c.chunk = &Chunk{make([]byte, 0), make([]Value, 0), make([]int, 0), make(map[int][]int), make([]LocalInfo, 0), make([]*JumpTable, 0)}
 		if err = c.advance(); err != nil {
 			return nil, err
 		}
@@ -634,14 +1419,75 @@ func (c *compiler) Run() (*Chunk, error) {
 				}
 				break
 			}
+			start := c.current
 			if err = c.declaration(); err != nil {
-				return nil, err
+				c.errors = append(c.errors, err)
+				var compilerErr *Error
+				if !errors.As(err, &compilerErr) {
+					return nil, c.errors[0]
+				}
+				if err = c.synchronize(); err == nil && c.current == start {
+					// Nothing was consumed, skip the token the error is at.
+					err = c.advance()
+				}
+				if err != nil {
+					c.errors = append(c.errors, err)
+					return nil, c.errors[0]
+				}
 			}
 		}
+		if len(c.errors) > 0 {
+			return nil, c.errors[0]
+		}
 		if err = c.consume(scanner.TokenEof, ErrMissingExprEnd); err != nil {
 			return nil, err
 		}
 		c.emitOperation(OperationReturn)
+		for _, t := range c.globalReads {
+			if _, ok := LookupNative(t.Lexeme); !ok && !c.globals[t.Lexeme] {
+				c.warn(WarnUndefinedGlobal, t)
+			}
+		}
+		for _, t := range c.localDeclarations {
+			if c.globals[t.Lexeme] {
+				c.warn(WarnShadowedVar, t)
+			}
+		}
 	}
 	return c.chunk, nil
 }
+
+// synchronize skips to the start of the next statement after an error, so
+// that the errors after it can be reported too. The statement may be nested
+// in blocks, compilation continues as if it were at the top level.
+func (c *compiler) synchronize() error {
+	c.scopeDepth = 0
+	c.locals = c.locals[:0]
+	c.loops = nil
+	for !c.check(scanner.TokenEof) {
+		if k := c.previous.Kind; k == scanner.TokenSemicolon || k == scanner.TokenRightBrace {
+			return nil
+		}
+		switch c.current.Kind {
+		case scanner.TokenVar, scanner.TokenIf, scanner.TokenWhile, scanner.TokenFor, scanner.TokenPrint,
+			scanner.TokenSwitch, scanner.TokenBreak, scanner.TokenContinue:
+			return nil
+		}
+		if err := c.advance(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *compiler) Errors() []error {
+	return c.errors
+}
+
+func (c *compiler) Warnings() []*Warning {
+	return c.warnings
+}
+
+func (c *compiler) Symbols() []*Symbol {
+	return c.symbols
+}
@@ -0,0 +1,100 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lukibw/abc/scanner"
+)
+
+func compile(t *testing.T, source string) *Chunk {
+	t.Helper()
+	chunk, err := New(scanner.New([]byte(source))).Run()
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return chunk
+}
+
+func countOperations(chunk *Chunk) map[Operation]int {
+	counts := make(map[Operation]int)
+	for offset := 0; offset < len(chunk.Code); offset += Operation(chunk.Code[offset]).Size() {
+		counts[Operation(chunk.Code[offset])]++
+	}
+	return counts
+}
+
+func TestSwitchNegativeCases(t *testing.T) {
+	chunk := compile(t, `var n = 1;
+switch (n) {
+  case -1: print "a";
+  case -2.5, -3n: print "b";
+  case 4: print "c";
+}`)
+	ops := countOperations(chunk)
+	if ops[OperationSwitch] != 1 || ops[OperationNegate] != 0 || ops[OperationEqual] != 0 {
+		t.Fatalf("operations = %v, want a jump table without negations or comparisons", ops)
+	}
+	if len(chunk.Tables) != 1 || len(chunk.Tables[0].Cases) != 4 {
+		t.Fatalf("tables = %v, want one with 4 cases", chunk.Tables)
+	}
+	for _, v := range []Value{NewInteger(-1), NewNumber(-2.5), NewInteger(-3), NewInteger(4)} {
+		if jump := chunk.Tables[0].Jump(v); jump == chunk.Tables[0].Default {
+			t.Errorf("Jump(%s) = default", v)
+		}
+	}
+}
+
+func TestUnreachableArms(t *testing.T) {
+	tests := []struct {
+		name string
+		arms []string
+		want []int
+	}{
+		{"after binding", []string{`x => 1`, `2 => 2`}, []int{2}},
+		{"guarded binding", []string{`x if x > 1 => 1`, `2 => 2`}, nil},
+		{"duplicate literal", []string{`1 => 1`, `2 => 2`, `1.0 => 3`}, []int{3}},
+		{"duplicate negative literal", []string{`-1 => 1`, `-1 => 2`, `1 => 3`}, []int{2}},
+		{"list covered by list", []string{`[a, _] => 1`, `[1, 2] => 2`, `[1] => 3`}, []int{2}},
+		{"nested list", []string{`[[a], b] => 1`, `[[1], [2]] => 2`, `[[1, 2], b] => 3`}, []int{2}},
+		{"literal element", []string{`[1, x] => 1`, `[1, 2] => 2`, `[2, 2] => 3`}, []int{2}},
+		{"record with fewer fields", []string{`{kind} => 1`, `{kind: "a", size} => 2`}, []int{2}},
+		{"record with more fields", []string{`{kind, size} => 1`, `{kind} => 2`}, nil},
+		{"nested record", []string{`{a: {b: 1}} => 1`, `{a: {b: 1, c}} => 2`, `{a: {b: 2}} => 3`}, []int{2}},
+		{"list is not a record", []string{`[x] => 1`, `{x} => 2`}, nil},
+		{"after irrefutable", []string{`[x] => 1`, `_ => 2`, `[y, z] => 3`}, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(scanner.New([]byte("var v = 1;\nprint match (v) {\n" + strings.Join(tt.arms, ",\n") + "\n};")))
+			if _, err := c.Run(); err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			got := make([]int, 0)
+			for _, w := range c.Warnings() {
+				if w.Kind == WarnUnreachableArm {
+					// The arms start on line 3.
+					got = append(got, w.Token.Line-2)
+				}
+			}
+			if fmt.Sprint(got) != fmt.Sprint(append([]int{}, tt.want...)) {
+				t.Fatalf("unreachable arms = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEmptyInterpolation(t *testing.T) {
+	for _, source := range []string{`print "${}";`, `print "a ${1} b ${ }";`} {
+		_, err := New(scanner.New([]byte(source))).Run()
+		var e *scanner.Error
+		if !errors.As(err, &e) || e.Kind != scanner.ErrEmptyInterpolation {
+			t.Fatalf("Run(%s) error = %v, want %v", source, err, scanner.ErrEmptyInterpolation)
+		}
+		if want := strings.LastIndex(source, "${") + 1; e.Column != want {
+			t.Errorf("Run(%s) error column = %d, want %d", source, e.Column, want)
+		}
+	}
+}
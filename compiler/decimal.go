@@ -0,0 +1,140 @@
+package compiler
+
+import (
+	"math/big"
+	"strings"
+)
+
+type RoundingMode int
+
+const (
+	RoundHalfEven RoundingMode = iota
+	RoundHalfUp
+	RoundHalfDown
+	RoundDown
+	RoundUp
+	RoundFloor
+	RoundCeiling
+)
+
+var roundingModes = map[RoundingMode]string{
+	RoundHalfEven: "half_even",
+	RoundHalfUp:   "half_up",
+	RoundHalfDown: "half_down",
+	RoundDown:     "down",
+	RoundUp:       "up",
+	RoundFloor:    "floor",
+	RoundCeiling:  "ceiling",
+}
+
+func (m RoundingMode) String() string {
+	return roundingModes[m]
+}
+
+func ParseRoundingMode(s string) (RoundingMode, bool) {
+	for m, name := range roundingModes {
+		if name == s {
+			return m, true
+		}
+	}
+	return 0, false
+}
+
+// Decimal is an exact decimal number with a fixed number of digits after
+// the point, its scale. Results of operations that need more digits are
+// rounded with the rounding mode of the decimal. The zero value is 0 with
+// scale 0.
+type Decimal struct {
+	unscaled *big.Int
+	scale    int
+	mode     RoundingMode
+}
+
+func pow10(n int) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(n)), nil)
+}
+
+// round divides num by the positive den, rounding the quotient to an
+// integer with the given mode.
+func round(num, den *big.Int, mode RoundingMode) *big.Int {
+	q, r := new(big.Int).QuoRem(num, den, new(big.Int))
+	if r.Sign() == 0 {
+		return q
+	}
+	negative := num.Sign() < 0
+	half := new(big.Int).Abs(r)
+	half.Lsh(half, 1)
+	var away bool
+	switch c := half.Cmp(den); mode {
+	case RoundHalfEven:
+		away = c > 0 || (c == 0 && q.Bit(0) == 1)
+	case RoundHalfUp:
+		away = c >= 0
+	case RoundHalfDown:
+		away = c > 0
+	case RoundDown:
+		away = false
+	case RoundUp:
+		away = true
+	case RoundFloor:
+		away = negative
+	case RoundCeiling:
+		away = !negative
+	}
+	if away {
+		if negative {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+	return q
+}
+
+// RoundDecimal rounds r to a decimal with the given scale.
+func RoundDecimal(r *big.Rat, scale int, mode RoundingMode) *Decimal {
+	num := new(big.Int).Mul(r.Num(), pow10(scale))
+	return &Decimal{round(num, r.Denom(), mode), scale, mode}
+}
+
+func (d *Decimal) Scale() int {
+	return d.scale
+}
+
+func (d *Decimal) Mode() RoundingMode {
+	return d.mode
+}
+
+// digits returns the unscaled value, which is nil for the zero value.
+func (d *Decimal) digits() *big.Int {
+	if d.unscaled == nil {
+		return new(big.Int)
+	}
+	return d.unscaled
+}
+
+func (d *Decimal) Rat() *big.Rat {
+	return new(big.Rat).SetFrac(d.digits(), pow10(d.scale))
+}
+
+func (d *Decimal) Sign() int {
+	return d.digits().Sign()
+}
+
+func (d *Decimal) Neg() *Decimal {
+	return &Decimal{new(big.Int).Neg(d.digits()), d.scale, d.mode}
+}
+
+func (d *Decimal) String() string {
+	digits := new(big.Int).Abs(d.digits()).String()
+	if d.scale > 0 {
+		if len(digits) <= d.scale {
+			digits = strings.Repeat("0", d.scale-len(digits)+1) + digits
+		}
+		digits = digits[:len(digits)-d.scale] + "." + digits[len(digits)-d.scale:]
+	}
+	if d.Sign() < 0 {
+		return "-" + digits
+	}
+	return digits
+}
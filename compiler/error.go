@@ -32,31 +32,97 @@ const (
 	ErrMissingExprRightParen
 	ErrMissingExprSemicolon
 	ErrMissingBlockRightBrace
+	ErrMissingPropertyName
+	ErrMissingIndexRightBracket
+	ErrMissingInterpolationEnd
+	ErrNumberRange
+	ErrTooManyArguments
+	ErrMissingCallRightParen
+	ErrInvalidIncrementTarget
+	ErrMissingConditionalColon
+	ErrBreakOutsideLoop
+	ErrContinueOutsideLoop
+	ErrUndefinedLabel
+	ErrLabelAlreadyDefined
+	ErrLabelTarget
+	ErrMissingJumpSemicolon
+	ErrSwitchLeftParen
+	ErrSwitchRightParen
+	ErrSwitchLeftBrace
+	ErrSwitchRightBrace
+	ErrMissingCase
+	ErrMissingCaseColon
+	ErrDuplicateCase
+	ErrDuplicateDefault
+	ErrTooManyJumpTables
+	ErrMatchLeftParen
+	ErrMatchRightParen
+	ErrMatchLeftBrace
+	ErrMatchRightBrace
+	ErrMissingArmArrow
+	ErrInvalidPattern
+	ErrMissingListPatternBracket
+	ErrMissingRecordPatternBrace
+	ErrMissingFieldName
+	ErrTooManyElements
 )
 
 var errorMessages = map[ErrorKind]string{
-	ErrTooManyConstants:       "too many constants in one chunk",
-	ErrTooManyLocals:          "too many local variables in function",
-	ErrTooBigJump:             "too much code to jump over",
-	ErrTooBigLoop:             "loop body too large",
-	ErrVarAlreadyDefined:      "already a variable with this name in this scope",
-	ErrVarOwnInitializer:      "cannot read local variable in its own intializer",
-	ErrIfLeftParen:            "missing '(' after 'if'",
-	ErrIfRightParen:           "missing ')' after condition",
-	ErrWhileLeftParen:         "missing '(' after 'while'",
-	ErrWhileRightParen:        "missing ')' after condition",
-	ErrForLeftParen:           "missing '(' after 'for'",
-	ErrForRightParen:          "missing ')' after for clauses",
-	ErrForConditionSemicolon:  "missing ';' after loop condition",
-	ErrInvalidAssignTarget:    "invalid assignment target",
-	ErrMissingVarName:         "missing variable name",
-	ErrMissingVarSemicolon:    "missing ';' after variable declaration",
-	ErrMissingValueSemicolon:  "missing ';' after value",
-	ErrMissingExpr:            "missing expression",
-	ErrMissingExprEnd:         "missing end of expression",
-	ErrMissingExprRightParen:  "missing ')' after expression",
-	ErrMissingExprSemicolon:   "missing ';' after expression",
-	ErrMissingBlockRightBrace: "missing '}' after block",
+	ErrTooManyConstants:          "too many constants in one chunk",
+	ErrTooManyLocals:             "too many local variables in function",
+	ErrTooBigJump:                "too much code to jump over",
+	ErrTooBigLoop:                "loop body too large",
+	ErrVarAlreadyDefined:         "already a variable with this name in this scope",
+	ErrVarOwnInitializer:         "cannot read local variable in its own intializer",
+	ErrIfLeftParen:               "missing '(' after 'if'",
+	ErrIfRightParen:              "missing ')' after condition",
+	ErrWhileLeftParen:            "missing '(' after 'while'",
+	ErrWhileRightParen:           "missing ')' after condition",
+	ErrForLeftParen:              "missing '(' after 'for'",
+	ErrForRightParen:             "missing ')' after for clauses",
+	ErrForConditionSemicolon:     "missing ';' after loop condition",
+	ErrInvalidAssignTarget:       "invalid assignment target",
+	ErrMissingVarName:            "missing variable name",
+	ErrMissingVarSemicolon:       "missing ';' after variable declaration",
+	ErrMissingValueSemicolon:     "missing ';' after value",
+	ErrMissingExpr:               "missing expression",
+	ErrMissingExprEnd:            "missing end of expression",
+	ErrMissingExprRightParen:     "missing ')' after expression",
+	ErrMissingExprSemicolon:      "missing ';' after expression",
+	ErrMissingBlockRightBrace:    "missing '}' after block",
+	ErrMissingPropertyName:       "missing property name after '.'",
+	ErrMissingIndexRightBracket:  "missing ']' after index",
+	ErrMissingInterpolationEnd:   "missing '}' after interpolated expression",
+	ErrNumberRange:               "number literal out of range",
+	ErrTooManyArguments:          "cannot have more than 255 arguments",
+	ErrMissingCallRightParen:     "missing ')' after arguments",
+	ErrInvalidIncrementTarget:    "missing variable name after '++' or '--'",
+	ErrMissingConditionalColon:   "missing ':' in conditional expression",
+	ErrBreakOutsideLoop:          "cannot use 'break' outside of a loop",
+	ErrContinueOutsideLoop:       "cannot use 'continue' outside of a loop",
+	ErrUndefinedLabel:            "no enclosing loop with this label",
+	ErrLabelAlreadyDefined:       "already an enclosing loop with this label",
+	ErrLabelTarget:               "label must be followed by a loop",
+	ErrMissingJumpSemicolon:      "missing ';' after 'break' or 'continue'",
+	ErrSwitchLeftParen:           "missing '(' after 'switch'",
+	ErrSwitchRightParen:          "missing ')' after switch value",
+	ErrSwitchLeftBrace:           "missing '{' before switch cases",
+	ErrSwitchRightBrace:          "missing '}' after switch cases",
+	ErrMissingCase:               "missing 'case' or 'default' in switch",
+	ErrMissingCaseColon:          "missing ':' after case",
+	ErrDuplicateCase:             "duplicate case value",
+	ErrDuplicateDefault:          "already a default case in this switch",
+	ErrTooManyJumpTables:         "too many switch statements in one chunk",
+	ErrMatchLeftParen:            "missing '(' after 'match'",
+	ErrMatchRightParen:           "missing ')' after match value",
+	ErrMatchLeftBrace:            "missing '{' before match arms",
+	ErrMatchRightBrace:           "missing '}' after match arms",
+	ErrMissingArmArrow:           "missing '=>' after pattern",
+	ErrInvalidPattern:            "invalid pattern",
+	ErrMissingListPatternBracket: "missing ']' after list pattern",
+	ErrMissingRecordPatternBrace: "missing '}' after record pattern",
+	ErrMissingFieldName:          "missing field name in record pattern",
+	ErrTooManyElements:           "cannot match more than 255 list elements",
 }
 
 func (k ErrorKind) String() string {
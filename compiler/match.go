@@ -0,0 +1,426 @@
+package compiler
+
+import (
+	"math"
+
+	"github.com/lukibw/abc/scanner"
+)
+
+// patternStep leads from a value to one of its list elements or, when field
+// is set, to one of its object fields.
+type patternStep struct {
+	index int
+	field string
+}
+
+type binding struct {
+	name *scanner.Token
+	path []patternStep
+}
+
+// arm collects what the pattern of a match arm needs once its tests are
+// emitted: the jumps taken when a test fails and the variables to bind.
+type arm struct {
+	fails    []int
+	bindings []binding
+}
+
+type shapeKind int
+
+const (
+	shapeAny shapeKind = iota
+	shapeLiteral
+	shapeList
+	shapeRecord
+	// shapeOpaque is a literal without a table key, such as NaN, which
+	// neither covers nor is covered by another pattern but a binding.
+	shapeOpaque
+)
+
+// shape describes the values a pattern matches, so that arms left
+// unreachable by an earlier arm can be reported.
+type shape struct {
+	kind   shapeKind
+	key    string
+	items  []*shape
+	fields map[string]*shape
+}
+
+// covers reports whether every value matched by o is matched by s. A record
+// covers the records with at least its fields, each of them covered.
+func (s *shape) covers(o *shape) bool {
+	switch {
+	case s.kind == shapeAny:
+		return true
+	case s.kind != o.kind:
+		return false
+	case s.kind == shapeLiteral:
+		return s.key == o.key
+	case s.kind == shapeList:
+		if len(s.items) != len(o.items) {
+			return false
+		}
+		for i, item := range s.items {
+			if !item.covers(o.items[i]) {
+				return false
+			}
+		}
+		return true
+	case s.kind == shapeRecord:
+		for name, f := range s.fields {
+			other, ok := o.fields[name]
+			if !ok || !f.covers(other) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+func extend(path []patternStep, s patternStep) []patternStep {
+	return append(path[:len(path):len(path)], s)
+}
+
+// emitPath pushes the part of the subject the path leads to.
+func (c *compiler) emitPath(subject uint8, path []patternStep) error {
+	c.emitOperation(OperationGetLocal)
+	c.emitByte(subject)
+	for _, s := range path {
+		if s.field != "" {
+			x, err := c.makeConstant(NewString(s.field))
+			if err != nil {
+				return err
+			}
+			c.emitOperation(OperationGetProperty)
+			c.emitByte(x)
+			continue
+		}
+		if err := c.emitConstant(NewInteger(int64(s.index))); err != nil {
+			return err
+		}
+		c.emitOperation(OperationGetIndex)
+	}
+	return nil
+}
+
+// emitTest jumps out of the arm when the boolean on the stack is false.
+func (c *compiler) emitTest(a *arm) {
+	a.fails = append(a.fails, c.emitJump(OperationJumpIfFalse))
+	c.emitOperation(OperationPop)
+}
+
+// pattern emits the tests of the pattern matched against the part of the
+// subject the path leads to. The tests of a list or record check its shape
+// before the tests of its elements, so that these can be read safely.
+func (c *compiler) pattern(subject uint8, path []patternStep, a *arm) (*shape, error) {
+	var err error
+	switch {
+	case c.check(scanner.TokenIdentifier):
+		if err = c.advance(); err != nil {
+			return nil, err
+		}
+		if c.previous.Lexeme != "_" {
+			a.bindings = append(a.bindings, binding{c.previous, path})
+		}
+		return &shape{kind: shapeAny}, nil
+	case c.check(scanner.TokenLeftBracket):
+		if err = c.advance(); err != nil {
+			return nil, err
+		}
+		if err = c.emitPath(subject, path); err != nil {
+			return nil, err
+		}
+		c.emitOperation(OperationMatchList)
+		// The length is patched once the elements are compiled.
+		c.emitByte(0)
+		length := len(c.chunk.Code) - 1
+		c.emitTest(a)
+		s := &shape{kind: shapeList}
+		for !c.check(scanner.TokenRightBracket) {
+			if len(s.items) == math.MaxUint8 {
+				return nil, &Error{ErrTooManyElements, c.current}
+			}
+			item, err := c.pattern(subject, extend(path, patternStep{index: len(s.items)}), a)
+			if err != nil {
+				return nil, err
+			}
+			s.items = append(s.items, item)
+			if !c.check(scanner.TokenComma) {
+				break
+			}
+			if err = c.advance(); err != nil {
+				return nil, err
+			}
+		}
+		c.chunk.Code[length] = byte(len(s.items))
+		return s, c.consume(scanner.TokenRightBracket, ErrMissingListPatternBracket)
+	case c.check(scanner.TokenLeftBrace):
+		if err = c.advance(); err != nil {
+			return nil, err
+		}
+		if err = c.emitPath(subject, path); err != nil {
+			return nil, err
+		}
+		c.emitOperation(OperationMatchObject)
+		c.emitTest(a)
+		s := &shape{kind: shapeRecord, fields: make(map[string]*shape)}
+		for !c.check(scanner.TokenRightBrace) {
+			if err = c.consume(scanner.TokenIdentifier, ErrMissingFieldName); err != nil {
+				return nil, err
+			}
+			name := c.previous
+			x, err := c.identifierConstant(name)
+			if err != nil {
+				return nil, err
+			}
+			if err = c.emitPath(subject, path); err != nil {
+				return nil, err
+			}
+			c.emitOperation(OperationMatchField)
+			c.emitByte(x)
+			c.emitTest(a)
+			field := extend(path, patternStep{field: name.Lexeme})
+			s.fields[name.Lexeme] = &shape{kind: shapeAny}
+			if c.check(scanner.TokenColon) {
+				if err = c.advance(); err != nil {
+					return nil, err
+				}
+				if s.fields[name.Lexeme], err = c.pattern(subject, field, a); err != nil {
+					return nil, err
+				}
+			} else if name.Lexeme != "_" {
+				a.bindings = append(a.bindings, binding{name, field})
+			}
+			if !c.check(scanner.TokenComma) {
+				break
+			}
+			if err = c.advance(); err != nil {
+				return nil, err
+			}
+		}
+		return s, c.consume(scanner.TokenRightBrace, ErrMissingRecordPatternBrace)
+	default:
+		if err = c.emitPath(subject, path); err != nil {
+			return nil, err
+		}
+		value, err := c.patternLiteral()
+		if err != nil {
+			return nil, err
+		}
+		c.emitOperation(OperationEqual)
+		c.emitTest(a)
+		if value != nil {
+			if k, ok := tableKey(*value); ok {
+				return &shape{kind: shapeLiteral, key: k}, nil
+			}
+		}
+		return &shape{kind: shapeOpaque}, nil
+	}
+}
+
+// patternLiteral emits a literal of a pattern and returns its value. A
+// negated number is folded into a constant, unless negating it fails.
+func (c *compiler) patternLiteral() (*Value, error) {
+	var err error
+	var value Value
+	switch {
+	case c.check(scanner.TokenMinus):
+		if err = c.advance(); err != nil {
+			return nil, err
+		}
+		if err = c.consume(scanner.TokenNumber, ErrInvalidPattern); err != nil {
+			return nil, err
+		}
+		if err = c.number(); err != nil {
+			return nil, err
+		}
+		last := len(c.chunk.Constants) - 1
+		n, ok := Negate(c.chunk.Constants[last])
+		if !ok {
+			c.emitOperation(OperationNegate)
+			return nil, nil
+		}
+		c.chunk.Constants[last] = n
+		value = n
+	case c.check(scanner.TokenNumber), c.check(scanner.TokenString):
+		if err = c.advance(); err != nil {
+			return nil, err
+		}
+		if c.previous.Kind == scanner.TokenNumber {
+			err = c.number()
+		} else {
+			err = c.string()
+		}
+		if err != nil {
+			return nil, err
+		}
+		value = c.chunk.Constants[len(c.chunk.Constants)-1]
+	case c.check(scanner.TokenNil), c.check(scanner.TokenFalse), c.check(scanner.TokenTrue):
+		if err = c.advance(); err != nil {
+			return nil, err
+		}
+		c.literal()
+		value = NewBoolean(c.previous.Kind == scanner.TokenTrue)
+		if c.previous.Kind == scanner.TokenNil {
+			value = NewNil()
+		}
+	default:
+		return nil, &Error{ErrInvalidPattern, c.current}
+	}
+	return &value, nil
+}
+
+// bind pushes the bound parts of the subject as the locals of an arm.
+func (c *compiler) bind(subject uint8, bindings []binding) error {
+	for i, b := range bindings {
+		for _, other := range bindings[:i] {
+			if other.name.Lexeme == b.name.Lexeme {
+				return &Error{ErrVarAlreadyDefined, b.name}
+			}
+		}
+		if c.height > math.MaxUint8 {
+			return &Error{ErrTooManyLocals, b.name}
+		}
+		slot := c.height
+		if err := c.emitPath(subject, b.path); err != nil {
+			return err
+		}
+		c.locals = append(c.locals, local{b.name, -1, false, c.addSymbol(b.name.Lexeme, false, b.name), -1, slot})
+		c.markInitialized()
+	}
+	return nil
+}
+
+// match compiles a match expression. The subject is kept in a local named
+// by the keyword, above the temporaries of the enclosing expression, and
+// the arms are tried in order. An arm runs the tests of its pattern, each
+// jumping to the next arm when it fails, then binds its variables, checks
+// its guard and evaluates its result into the slot of the subject, which is
+// left on the stack as the value of the expression.
+func (c *compiler) match() error {
+	var err error
+	keyword := c.previous
+	if err = c.consume(scanner.TokenLeftParen, ErrMatchLeftParen); err != nil {
+		return err
+	}
+	slot := c.height
+	if slot > math.MaxUint8 {
+		return &Error{ErrTooManyLocals, keyword}
+	}
+	subject := uint8(slot)
+	if err = c.expression(); err != nil {
+		return err
+	}
+	if err = c.consume(scanner.TokenRightParen, ErrMatchRightParen); err != nil {
+		return err
+	}
+	if err = c.consume(scanner.TokenLeftBrace, ErrMatchLeftBrace); err != nil {
+		return err
+	}
+	c.beginScope()
+	c.locals = append(c.locals, local{name: keyword, used: true, slot: slot})
+	c.markInitialized()
+	endJumps := make([]int, 0)
+	// covering holds the shapes of the arms without a guard so far.
+	covering := make([]*shape, 0)
+	for !c.check(scanner.TokenRightBrace) && !c.check(scanner.TokenEof) {
+		start := c.current
+		var a arm
+		s, err := c.pattern(subject, nil, &a)
+		if err != nil {
+			return err
+		}
+		c.beginScope()
+		if err = c.bind(subject, a.bindings); err != nil {
+			return err
+		}
+		guardJump := -1
+		if c.check(scanner.TokenIf) {
+			if err = c.advance(); err != nil {
+				return err
+			}
+			if err = c.expression(); err != nil {
+				return err
+			}
+			guardJump = c.emitJump(OperationJumpIfFalse)
+			c.emitOperation(OperationPop)
+		}
+		if err = c.consume(scanner.TokenEqualGreater, ErrMissingArmArrow); err != nil {
+			return err
+		}
+		if err = c.expression(); err != nil {
+			return err
+		}
+		c.emitOperation(OperationSetLocal)
+		c.emitByte(subject)
+		c.emitOperation(OperationPop)
+		for range a.bindings {
+			c.emitOperation(OperationPop)
+		}
+		endJumps = append(endJumps, c.emitJump(OperationJump))
+		nextJump := -1
+		if guardJump >= 0 {
+			if err = c.patchJump(guardJump); err != nil {
+				return err
+			}
+			c.height = slot + len(a.bindings) + 2
+			for i := 0; i <= len(a.bindings); i++ {
+				c.emitOperation(OperationPop)
+			}
+			if len(a.fails) > 0 {
+				nextJump = c.emitJump(OperationJump)
+			}
+		}
+		c.leaveScope(false)
+		if len(a.fails) > 0 {
+			for _, offset := range a.fails {
+				if err = c.patchJump(offset); err != nil {
+					return err
+				}
+			}
+			c.height = slot + 2
+			c.emitOperation(OperationPop)
+		}
+		if nextJump >= 0 {
+			if err = c.patchJump(nextJump); err != nil {
+				return err
+			}
+		}
+		c.height = slot + 1
+		for _, earlier := range covering {
+			if earlier.covers(s) {
+				c.warn(WarnUnreachableArm, start)
+				break
+			}
+		}
+		if guardJump < 0 {
+			covering = append(covering, s)
+		}
+		if !c.check(scanner.TokenComma) {
+			break
+		}
+		if err = c.advance(); err != nil {
+			return err
+		}
+	}
+	if err = c.consume(scanner.TokenRightBrace, ErrMatchRightBrace); err != nil {
+		return err
+	}
+	exhaustive := false
+	for _, s := range covering {
+		exhaustive = exhaustive || s.kind == shapeAny
+	}
+	if !exhaustive {
+		c.chunk.writeOperation(OperationNoMatch, keyword.Line)
+	}
+	for _, offset := range endJumps {
+		if err = c.patchJump(offset); err != nil {
+			return err
+		}
+	}
+	c.leaveScope(false)
+	c.height = slot + 1
+	return nil
+}
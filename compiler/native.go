@@ -0,0 +1,146 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// Native is a function implemented in Go which scripts can call. Natives
+// are predeclared globals, a script may redefine them.
+type Native struct {
+	Name    string
+	MinArgs int
+	MaxArgs int
+	Call    func(args []Value) (Value, error)
+}
+
+// maxScale bounds the scale of decimals created by scripts.
+const maxScale = 1000
+
+var natives = map[string]*Native{
+	"int":     {"int", 1, 1, toInteger},
+	"float":   {"float", 1, 1, toFloat},
+	"bigint":  {"bigint", 1, 1, toBigInteger},
+	"decimal": {"decimal", 2, 3, toDecimal},
+}
+
+// LookupNative returns the native function with the given name.
+func LookupNative(name string) (*Native, bool) {
+	n, ok := natives[name]
+	return n, ok
+}
+
+func conversionError(v Value, to string) error {
+	return fmt.Errorf("cannot convert %s %s to %s", v.Type(), v, to)
+}
+
+// truncate returns the integer part of an exact number or of a finite float.
+func truncate(v Value, to string) (*big.Int, error) {
+	r, ok := Rat(v)
+	if !ok {
+		return nil, conversionError(v, to)
+	}
+	return new(big.Int).Quo(r.Num(), r.Denom()), nil
+}
+
+func toInteger(args []Value) (Value, error) {
+	v := args[0]
+	switch {
+	case v.IsInteger():
+		return v, nil
+	case v.IsNumber():
+		n, err := truncate(v, "int")
+		if err != nil {
+			return Value{}, err
+		}
+		if !n.IsInt64() {
+			return Value{}, conversionError(v, "int")
+		}
+		return NewInteger(n.Int64()), nil
+	case v.IsString():
+		n, err := strconv.ParseInt(v.AsString(), 10, 64)
+		if err != nil {
+			return Value{}, conversionError(v, "int")
+		}
+		return NewInteger(n), nil
+	default:
+		return Value{}, conversionError(v, "int")
+	}
+}
+
+func toFloat(args []Value) (Value, error) {
+	v := args[0]
+	switch {
+	case v.IsNumber():
+		return NewNumber(v.AsNumber()), nil
+	case v.IsString():
+		f, err := strconv.ParseFloat(v.AsString(), 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return Value{}, conversionError(v, "float")
+		}
+		return NewNumber(f), nil
+	default:
+		return Value{}, conversionError(v, "float")
+	}
+}
+
+func toBigInteger(args []Value) (Value, error) {
+	v := args[0]
+	switch {
+	case v.IsBigInteger():
+		return v, nil
+	case v.IsNumber():
+		n, err := truncate(v, "bigint")
+		if err != nil {
+			return Value{}, err
+		}
+		return NewBigInteger(n), nil
+	case v.IsString():
+		n, ok := new(big.Int).SetString(v.AsString(), 10)
+		if !ok {
+			return Value{}, conversionError(v, "bigint")
+		}
+		return NewBigInteger(n), nil
+	default:
+		return Value{}, conversionError(v, "bigint")
+	}
+}
+
+// toDecimal converts its first argument to a decimal with the scale given by
+// the second one, rounding with the mode named by the optional third one.
+func toDecimal(args []Value) (Value, error) {
+	v := args[0]
+	scale := args[1]
+	if !scale.IsInteger() || scale.AsInteger() < 0 || scale.AsInteger() > maxScale {
+		return Value{}, fmt.Errorf("scale must be an integer from 0 to %d", maxScale)
+	}
+	mode := RoundHalfEven
+	if len(args) == 3 {
+		var ok bool
+		if args[2].IsString() {
+			mode, ok = ParseRoundingMode(args[2].AsString())
+		}
+		if !ok {
+			return Value{}, fmt.Errorf("unknown rounding mode %s", args[2])
+		}
+	}
+	var r *big.Rat
+	switch {
+	case v.IsNumber():
+		var ok bool
+		if r, ok = Rat(v); !ok {
+			return Value{}, conversionError(v, "decimal")
+		}
+	case v.IsString() && !strings.Contains(v.AsString(), "/"):
+		var ok bool
+		if r, ok = new(big.Rat).SetString(v.AsString()); !ok {
+			return Value{}, conversionError(v, "decimal")
+		}
+	default:
+		return Value{}, conversionError(v, "decimal")
+	}
+	return NewDecimal(RoundDecimal(r, int(scale.AsInteger()), mode)), nil
+}
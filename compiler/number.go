@@ -0,0 +1,92 @@
+package compiler
+
+import (
+	"math"
+	"math/big"
+)
+
+// maxExactFloat is the largest magnitude up to which every integer is
+// exactly representable as a float.
+const maxExactFloat = 1 << 53
+
+// Negate returns the negation of a number as the NEGATE operation computes
+// it, it reports false when that would fail.
+func Negate(v Value) (Value, bool) {
+	switch {
+	case v.IsInteger() && v.AsInteger() != math.MinInt64:
+		return NewInteger(-v.AsInteger()), true
+	case v.IsFloat():
+		return NewNumber(-v.AsNumber()), true
+	case v.IsBigInteger():
+		return NewBigInteger(new(big.Int).Neg(v.AsBigInteger())), true
+	case v.IsDecimal():
+		return NewDecimal(v.AsDecimal().Neg()), true
+	default:
+		return Value{}, false
+	}
+}
+
+// Rat returns the exact value of a number, it reports false for floats that
+// are infinite or not a number.
+func Rat(v Value) (*big.Rat, bool) {
+	switch x := v.as.(type) {
+	case int64:
+		return new(big.Rat).SetInt64(x), true
+	case *big.Int:
+		return new(big.Rat).SetInt(x), true
+	case *Decimal:
+		return x.Rat(), true
+	default:
+		f := x.(float64)
+		if math.IsInf(f, 0) || math.IsNaN(f) {
+			return nil, false
+		}
+		return new(big.Rat).SetFloat64(f), true
+	}
+}
+
+// Compare returns -1, 0 or 1 as the number a is less than, equal to or
+// greater than the number b. Numbers of different kinds are compared by
+// their exact values. It reports false when a float that is not a number
+// is involved.
+func Compare(a, b Value) (int, bool) {
+	if a.IsInteger() && b.IsInteger() {
+		x, y := a.AsInteger(), b.AsInteger()
+		switch {
+		case x < y:
+			return -1, true
+		case x > y:
+			return 1, true
+		default:
+			return 0, true
+		}
+	}
+	x, xok := Rat(a)
+	y, yok := Rat(b)
+	if xok && yok && !(a.IsFloat() && b.IsFloat()) && !(fits(a) && fits(b)) {
+		return x.Cmp(y), true
+	}
+	f, g := a.AsNumber(), b.AsNumber()
+	switch {
+	case math.IsNaN(f) || math.IsNaN(g):
+		return 0, false
+	case f < g:
+		return -1, true
+	case f > g:
+		return 1, true
+	default:
+		return 0, true
+	}
+}
+
+// fits reports whether comparing the number as a float is exact.
+func fits(v Value) bool {
+	switch {
+	case v.IsFloat():
+		return true
+	case v.IsInteger():
+		return v.AsInteger() >= -maxExactFloat && v.AsInteger() <= maxExactFloat
+	default:
+		return false
+	}
+}
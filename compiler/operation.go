@@ -27,6 +27,25 @@ const (
 	OperationJump
 	OperationJumpIfFalse
 	OperationLoop
+	OperationGetProperty
+	OperationGetIndex
+	OperationStringify
+	OperationCall
+	OperationFloorDivide
+	OperationModulo
+	OperationPower
+	OperationBitAnd
+	OperationBitOr
+	OperationBitXor
+	OperationBitNot
+	OperationShiftLeft
+	OperationShiftRight
+	OperationJumpIfNotNil
+	OperationSwitch
+	OperationMatchList
+	OperationMatchObject
+	OperationMatchField
+	OperationNoMatch
 )
 
 var operations = map[Operation]string{
@@ -54,8 +73,69 @@ var operations = map[Operation]string{
 	OperationJump:         "JUMP",
 	OperationJumpIfFalse:  "JUMP_IF_FALSE",
 	OperationLoop:         "LOOP",
+	OperationGetProperty:  "GET_PROPERTY",
+	OperationGetIndex:     "GET_INDEX",
+	OperationStringify:    "STRINGIFY",
+	OperationCall:         "CALL",
+	OperationFloorDivide:  "FLOOR_DIVIDE",
+	OperationModulo:       "MODULO",
+	OperationPower:        "POWER",
+	OperationBitAnd:       "BIT_AND",
+	OperationBitOr:        "BIT_OR",
+	OperationBitXor:       "BIT_XOR",
+	OperationBitNot:       "BIT_NOT",
+	OperationShiftLeft:    "SHIFT_LEFT",
+	OperationShiftRight:   "SHIFT_RIGHT",
+	OperationJumpIfNotNil: "JUMP_IF_NOT_NIL",
+	OperationSwitch:       "SWITCH",
+	OperationMatchList:    "MATCH_LIST",
+	OperationMatchObject:  "MATCH_OBJECT",
+	OperationMatchField:   "MATCH_FIELD",
+	OperationNoMatch:      "NO_MATCH",
+}
+
+// stackEffects holds the change of the stack height caused by operations
+// other than CALL, which pops its arguments too.
+var stackEffects = map[Operation]int{
+	OperationConstant:     1,
+	OperationPrint:        -1,
+	OperationPop:          -1,
+	OperationDefineGlobal: -1,
+	OperationGetGlobal:    1,
+	OperationGetLocal:     1,
+	OperationAdd:          -1,
+	OperationSubtract:     -1,
+	OperationMultiply:     -1,
+	OperationDivide:       -1,
+	OperationNil:          1,
+	OperationFalse:        1,
+	OperationTrue:         1,
+	OperationEqual:        -1,
+	OperationGreater:      -1,
+	OperationLess:         -1,
+	OperationGetIndex:     -1,
+	OperationFloorDivide:  -1,
+	OperationModulo:       -1,
+	OperationPower:        -1,
+	OperationBitAnd:       -1,
+	OperationBitOr:        -1,
+	OperationBitXor:       -1,
+	OperationShiftLeft:    -1,
+	OperationShiftRight:   -1,
+	OperationSwitch:       -1,
 }
 
 func (o Operation) String() string {
 	return operations[o]
 }
+
+func (o Operation) Size() int {
+	switch o {
+	case OperationJump, OperationJumpIfFalse, OperationJumpIfNotNil, OperationLoop:
+		return 3
+	case OperationGetLocal, OperationSetLocal, OperationCall, OperationConstant, OperationDefineGlobal, OperationGetGlobal, OperationSetGlobal, OperationGetProperty, OperationSwitch, OperationMatchList, OperationMatchField:
+		return 2
+	default:
+		return 1
+	}
+}
@@ -7,13 +7,20 @@ type precedence int
 const (
 	precedenceNone precedence = iota
 	precedenceAssignment
+	precedenceConditional
+	precedenceCoalesce
 	precedenceOr
 	precedenceAnd
 	precedenceEquality
 	precedenceComparison
+	precedenceBitOr
+	precedenceBitXor
+	precedenceBitAnd
+	precedenceShift
 	precedenceTerm
 	precedenceFactor
 	precedenceUnary
+	precedenceExponent
 	precedenceCall
 	precedencePrimary
 )
@@ -28,9 +35,17 @@ const (
 	parseFunctionGrouping
 	parseFunctionLiteral
 	parseFunctionString
+	parseFunctionInterpolation
 	parseFunctionVariable
 	parseFunctionAnd
 	parseFunctionOr
+	parseFunctionDot
+	parseFunctionIndex
+	parseFunctionCall
+	parseFunctionIncrement
+	parseFunctionConditional
+	parseFunctionCoalesce
+	parseFunctionMatch
 )
 
 type parseRule struct {
@@ -40,43 +55,67 @@ type parseRule struct {
 }
 
 var parseRules = map[scanner.TokenKind]parseRule{
-	scanner.TokenLeftParen:    {parseFunctionGrouping, parseFunctionNone, precedenceNone},
-	scanner.TokenRightParen:   {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenLeftBrace:    {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenRightBrace:   {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenComma:        {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenDot:          {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenMinus:        {parseFunctionUnary, parseFunctionBinary, precedenceTerm},
-	scanner.TokenPlus:         {parseFunctionNone, parseFunctionBinary, precedenceTerm},
-	scanner.TokenSemicolon:    {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenSlash:        {parseFunctionNone, parseFunctionBinary, precedenceFactor},
-	scanner.TokenStar:         {parseFunctionNone, parseFunctionBinary, precedenceFactor},
-	scanner.TokenBang:         {parseFunctionUnary, parseFunctionNone, precedenceNone},
-	scanner.TokenBangEqual:    {parseFunctionNone, parseFunctionBinary, precedenceEquality},
-	scanner.TokenEqual:        {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenEqualEqual:   {parseFunctionNone, parseFunctionBinary, precedenceEquality},
-	scanner.TokenGreater:      {parseFunctionNone, parseFunctionBinary, precedenceComparison},
-	scanner.TokenGreaterEqual: {parseFunctionNone, parseFunctionBinary, precedenceComparison},
-	scanner.TokenLess:         {parseFunctionNone, parseFunctionBinary, precedenceComparison},
-	scanner.TokenLessEqual:    {parseFunctionNone, parseFunctionBinary, precedenceComparison},
-	scanner.TokenIdentifier:   {parseFunctionVariable, parseFunctionNone, precedenceNone},
-	scanner.TokenString:       {parseFunctionString, parseFunctionNone, precedenceNone},
-	scanner.TokenNumber:       {parseFunctionNumber, parseFunctionNone, precedenceNone},
-	scanner.TokenAnd:          {parseFunctionNone, parseFunctionAnd, precedenceAnd},
-	scanner.TokenClass:        {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenElse:         {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenFalse:        {parseFunctionLiteral, parseFunctionNone, precedenceNone},
-	scanner.TokenFor:          {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenFun:          {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenIf:           {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenNil:          {parseFunctionLiteral, parseFunctionNone, precedenceNone},
-	scanner.TokenOr:           {parseFunctionNone, parseFunctionOr, precedenceOr},
-	scanner.TokenPrint:        {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenReturn:       {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenSuper:        {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenThis:         {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenTrue:         {parseFunctionLiteral, parseFunctionNone, precedenceNone},
-	scanner.TokenVar:          {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenWhile:        {parseFunctionNone, parseFunctionNone, precedenceNone},
-	scanner.TokenEof:          {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenLeftParen:        {parseFunctionGrouping, parseFunctionCall, precedenceCall},
+	scanner.TokenRightParen:       {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenLeftBrace:        {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenRightBrace:       {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenLeftBracket:      {parseFunctionNone, parseFunctionIndex, precedenceCall},
+	scanner.TokenRightBracket:     {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenComma:            {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenDot:              {parseFunctionNone, parseFunctionDot, precedenceCall},
+	scanner.TokenMinus:            {parseFunctionUnary, parseFunctionBinary, precedenceTerm},
+	scanner.TokenPlus:             {parseFunctionNone, parseFunctionBinary, precedenceTerm},
+	scanner.TokenQuestion:         {parseFunctionNone, parseFunctionConditional, precedenceConditional},
+	scanner.TokenQuestionQuestion: {parseFunctionNone, parseFunctionCoalesce, precedenceCoalesce},
+	scanner.TokenColon:            {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenPlusPlus:         {parseFunctionIncrement, parseFunctionNone, precedenceNone},
+	scanner.TokenMinusMinus:       {parseFunctionIncrement, parseFunctionNone, precedenceNone},
+	scanner.TokenSemicolon:        {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenSlash:            {parseFunctionNone, parseFunctionBinary, precedenceFactor},
+	scanner.TokenStarStar:         {parseFunctionNone, parseFunctionBinary, precedenceExponent},
+	scanner.TokenPercent:          {parseFunctionNone, parseFunctionBinary, precedenceFactor},
+	scanner.TokenTildeSlash:       {parseFunctionNone, parseFunctionBinary, precedenceFactor},
+	scanner.TokenStar:             {parseFunctionNone, parseFunctionBinary, precedenceFactor},
+	scanner.TokenBang:             {parseFunctionUnary, parseFunctionNone, precedenceNone},
+	scanner.TokenBangEqual:        {parseFunctionNone, parseFunctionBinary, precedenceEquality},
+	scanner.TokenEqual:            {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenEqualEqual:       {parseFunctionNone, parseFunctionBinary, precedenceEquality},
+	scanner.TokenGreater:          {parseFunctionNone, parseFunctionBinary, precedenceComparison},
+	scanner.TokenGreaterEqual:     {parseFunctionNone, parseFunctionBinary, precedenceComparison},
+	scanner.TokenLess:             {parseFunctionNone, parseFunctionBinary, precedenceComparison},
+	scanner.TokenLessEqual:        {parseFunctionNone, parseFunctionBinary, precedenceComparison},
+	scanner.TokenLessLess:         {parseFunctionNone, parseFunctionBinary, precedenceShift},
+	scanner.TokenGreaterGreater:   {parseFunctionNone, parseFunctionBinary, precedenceShift},
+	scanner.TokenAmpersand:        {parseFunctionNone, parseFunctionBinary, precedenceBitAnd},
+	scanner.TokenPipe:             {parseFunctionNone, parseFunctionBinary, precedenceBitOr},
+	scanner.TokenCaret:            {parseFunctionNone, parseFunctionBinary, precedenceBitXor},
+	scanner.TokenTilde:            {parseFunctionUnary, parseFunctionNone, precedenceNone},
+	scanner.TokenIdentifier:       {parseFunctionVariable, parseFunctionNone, precedenceNone},
+	scanner.TokenString:           {parseFunctionString, parseFunctionNone, precedenceNone},
+	scanner.TokenInterpolation:    {parseFunctionInterpolation, parseFunctionNone, precedenceNone},
+	scanner.TokenNumber:           {parseFunctionNumber, parseFunctionNone, precedenceNone},
+	scanner.TokenAnd:              {parseFunctionNone, parseFunctionAnd, precedenceAnd},
+	scanner.TokenClass:            {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenElse:             {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenFalse:            {parseFunctionLiteral, parseFunctionNone, precedenceNone},
+	scanner.TokenFor:              {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenFun:              {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenIf:               {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenNil:              {parseFunctionLiteral, parseFunctionNone, precedenceNone},
+	scanner.TokenOr:               {parseFunctionNone, parseFunctionOr, precedenceOr},
+	scanner.TokenPrint:            {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenReturn:           {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenSuper:            {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenThis:             {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenTrue:             {parseFunctionLiteral, parseFunctionNone, precedenceNone},
+	scanner.TokenVar:              {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenWhile:            {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenBreak:            {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenContinue:         {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenSwitch:           {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenCase:             {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenDefault:          {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenMatch:            {parseFunctionMatch, parseFunctionNone, precedenceNone},
+	scanner.TokenEqualGreater:     {parseFunctionNone, parseFunctionNone, precedenceNone},
+	scanner.TokenEof:              {parseFunctionNone, parseFunctionNone, precedenceNone},
 }
@@ -0,0 +1,18 @@
+package compiler
+
+import "github.com/lukibw/abc/scanner"
+
+type Symbol struct {
+	Name        string
+	Global      bool
+	Declaration *scanner.Token
+	References  []*scanner.Token
+}
+
+func newSymbol(name string, global bool, declaration *scanner.Token) *Symbol {
+	return &Symbol{name, global, declaration, make([]*scanner.Token, 0)}
+}
+
+func (s *Symbol) reference(t *scanner.Token) {
+	s.References = append(s.References, t)
+}
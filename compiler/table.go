@@ -0,0 +1,43 @@
+package compiler
+
+import "fmt"
+
+// JumpTable maps the constant case values of a switch statement to the
+// jumps to their bodies. Jumps are relative to the end of the SWITCH
+// operation, like the operand of the other jumps.
+type JumpTable struct {
+	Cases   map[string]int
+	Default int
+}
+
+// tableKey returns a key under which values equal to v are stored. Numbers
+// are keyed by their exact value, so 1, 1.0 and 1n share a key. Values
+// without one, such as lists or NaN, never match a case.
+func tableKey(v Value) (string, bool) {
+	switch {
+	case v.IsNil():
+		return "nil", true
+	case v.IsBoolean():
+		return fmt.Sprint(v.AsBoolean()), true
+	case v.IsString():
+		return "s" + v.AsString(), true
+	case v.IsNumber():
+		r, ok := Rat(v)
+		if !ok {
+			return "", false
+		}
+		return "n" + r.RatString(), true
+	default:
+		return "", false
+	}
+}
+
+// Jump returns the jump to the case matching v or to the default.
+func (t *JumpTable) Jump(v Value) int {
+	if k, ok := tableKey(v); ok {
+		if jump, ok := t.Cases[k]; ok {
+			return jump
+		}
+	}
+	return t.Default
+}
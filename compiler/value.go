@@ -1,11 +1,25 @@
 package compiler
 
-import "fmt"
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strconv"
+	"strings"
+)
 
 type Value struct {
 	as any
 }
 
+type list struct {
+	items []Value
+}
+
+type object struct {
+	fields map[string]Value
+}
+
 func NewNil() Value {
 	return Value{nil}
 }
@@ -18,15 +32,98 @@ func NewNumber(n float64) Value {
 	return Value{n}
 }
 
+func NewInteger(n int64) Value {
+	return Value{n}
+}
+
+func NewBigInteger(n *big.Int) Value {
+	return Value{n}
+}
+
+func NewDecimal(d *Decimal) Value {
+	return Value{d}
+}
+
 func NewString(s string) Value {
 	return Value{s}
 }
 
+func NewNative(n *Native) Value {
+	return Value{n}
+}
+
+func NewList(items []Value) Value {
+	return Value{&list{items}}
+}
+
+func NewObject(fields map[string]Value) Value {
+	return Value{&object{fields}}
+}
+
 func (v Value) String() string {
-	if v.as == nil {
+	switch x := v.as.(type) {
+	case nil:
+		return "nil"
+	case float64:
+		// Floats always show a fraction or an exponent, so they can be
+		// told apart from integers.
+		f := strconv.FormatFloat(x, 'g', -1, 64)
+		if !strings.ContainsAny(f, ".eIN") {
+			f += ".0"
+		}
+		return f
+	case *big.Int:
+		return x.String()
+	case *Decimal:
+		return x.String()
+	case *Native:
+		return fmt.Sprintf("<native %s>", x.Name)
+	case *list:
+		items := make([]string, len(x.items))
+		for i, item := range x.items {
+			items[i] = item.String()
+		}
+		return fmt.Sprintf("[%s]", strings.Join(items, ", "))
+	case *object:
+		names := make([]string, 0, len(x.fields))
+		for name := range x.fields {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fields := make([]string, len(names))
+		for i, name := range names {
+			fields[i] = fmt.Sprintf("%s: %s", name, x.fields[name])
+		}
+		return fmt.Sprintf("{%s}", strings.Join(fields, ", "))
+	default:
+		return fmt.Sprint(v.as)
+	}
+}
+
+// Type returns the name of the kind of the value.
+func (v Value) Type() string {
+	switch v.as.(type) {
+	case nil:
 		return "nil"
+	case bool:
+		return "boolean"
+	case int64:
+		return "integer"
+	case float64:
+		return "float"
+	case *big.Int:
+		return "bigint"
+	case *Decimal:
+		return "decimal"
+	case string:
+		return "string"
+	case *list:
+		return "list"
+	case *object:
+		return "object"
+	default:
+		return "native"
 	}
-	return fmt.Sprint(v.as)
 }
 
 func (v Value) IsNil() bool {
@@ -37,16 +134,62 @@ func (v Value) IsBoolean() bool {
 	return v.as == true || v.as == false
 }
 
+// IsNumber reports whether the value is a float, an integer, a big integer
+// or a decimal.
 func (v Value) IsNumber() bool {
+	switch v.as.(type) {
+	case float64, int64, *big.Int, *Decimal:
+		return true
+	default:
+		return false
+	}
+}
+
+func (v Value) IsFloat() bool {
 	_, ok := v.as.(float64)
 	return ok
 }
 
+func (v Value) IsInteger() bool {
+	_, ok := v.as.(int64)
+	return ok
+}
+
+func (v Value) IsBigInteger() bool {
+	_, ok := v.as.(*big.Int)
+	return ok
+}
+
+func (v Value) IsDecimal() bool {
+	_, ok := v.as.(*Decimal)
+	return ok
+}
+
+// IsExact reports whether the value is a number other than a float.
+func (v Value) IsExact() bool {
+	return v.IsNumber() && !v.IsFloat()
+}
+
 func (v Value) IsString() bool {
 	_, ok := v.as.(string)
 	return ok
 }
 
+func (v Value) IsList() bool {
+	_, ok := v.as.(*list)
+	return ok
+}
+
+func (v Value) IsObject() bool {
+	_, ok := v.as.(*object)
+	return ok
+}
+
+func (v Value) IsNative() bool {
+	_, ok := v.as.(*Native)
+	return ok
+}
+
 func (v Value) IsFalsey() bool {
 	return v.IsNil() || (v.IsBoolean() && !v.AsBoolean())
 }
@@ -55,10 +198,57 @@ func (v Value) AsBoolean() bool {
 	return v.as.(bool)
 }
 
+// AsNumber returns the value of any number converted to float.
 func (v Value) AsNumber() float64 {
-	return v.as.(float64)
+	switch x := v.as.(type) {
+	case int64:
+		return float64(x)
+	case *big.Int:
+		f, _ := new(big.Float).SetInt(x).Float64()
+		return f
+	case *Decimal:
+		f, _ := x.Rat().Float64()
+		return f
+	default:
+		return x.(float64)
+	}
+}
+
+func (v Value) AsInteger() int64 {
+	return v.as.(int64)
+}
+
+func (v Value) AsBigInteger() *big.Int {
+	return v.as.(*big.Int)
+}
+
+func (v Value) AsDecimal() *Decimal {
+	return v.as.(*Decimal)
+}
+
+func (v Value) AsNative() *Native {
+	return v.as.(*Native)
 }
 
 func (v Value) AsString() string {
 	return v.as.(string)
 }
+
+func (v Value) AsList() []Value {
+	return v.as.(*list).items
+}
+
+func (v Value) AsObject() map[string]Value {
+	return v.as.(*object).fields
+}
+
+// Equal reports whether two values are equal. Lists and objects are equal
+// only to themselves, numbers of different kinds are equal when their exact
+// values are.
+func (v Value) Equal(o Value) bool {
+	if v.IsNumber() && o.IsNumber() {
+		c, ok := Compare(v, o)
+		return ok && c == 0
+	}
+	return v == o
+}
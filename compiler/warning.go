@@ -0,0 +1,50 @@
+package compiler
+
+import (
+	"fmt"
+
+	"github.com/lukibw/abc/scanner"
+)
+
+type WarningKind int
+
+const (
+	WarnUnusedLocal WarningKind = iota
+	WarnShadowedVar
+	WarnConstantCondition
+	WarnUndefinedGlobal
+	WarnUnreachableArm
+)
+
+var warningMessages = map[WarningKind]string{
+	WarnUnusedLocal:       "local variable is never used",
+	WarnShadowedVar:       "declaration shadows a variable from an outer scope",
+	WarnConstantCondition: "condition is always the same",
+	WarnUndefinedGlobal:   "global variable is never defined",
+	WarnUnreachableArm:    "match arm is never reached",
+}
+
+var warningRules = map[WarningKind]string{
+	WarnUnusedLocal:       "unused-local",
+	WarnShadowedVar:       "shadowed-variable",
+	WarnConstantCondition: "constant-condition",
+	WarnUndefinedGlobal:   "undefined-global",
+	WarnUnreachableArm:    "unreachable-arm",
+}
+
+func (k WarningKind) String() string {
+	return warningMessages[k]
+}
+
+func (k WarningKind) Rule() string {
+	return warningRules[k]
+}
+
+type Warning struct {
+	Kind  WarningKind
+	Token *scanner.Token
+}
+
+func (w *Warning) String() string {
+	return fmt.Sprintf("[line %d] warning at '%s': %s [%s]", w.Token.Line, w.Token.Lexeme, w.Kind, w.Kind.Rule())
+}
@@ -0,0 +1,256 @@
+package cover
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/vm"
+)
+
+type Coverage interface {
+	Hook(v vm.VM) error
+	Summary(w io.Writer) error
+	WriteLCOV(w io.Writer) error
+	WriteHTML(w io.Writer) error
+}
+
+func New(name string, source []byte) Coverage {
+	return &coverage{name, strings.Split(string(source), "\n"), nil, make(map[int]int), make(map[int]*branch), make(map[int][]int), make(map[int]bool), 0}
+}
+
+type branch struct {
+	line  int
+	taken int
+	skip  int
+}
+
+type coverage struct {
+	name       string
+	source     []string
+	statements map[int][]int
+	lines      map[int]int
+	branches   map[int]*branch
+	lineJumps  map[int][]int
+	// ownLines are the lines with instructions, line is the line of the
+	// last instruction.
+	ownLines map[int]bool
+	line     int
+}
+
+func (c *coverage) init(chunk *compiler.Chunk) {
+	c.statements = make(map[int][]int)
+	for line, offsets := range chunk.LineOffsets {
+		c.lines[line] = 0
+		for _, offset := range offsets {
+			c.statements[offset] = append(c.statements[offset], line)
+		}
+	}
+	for offset := 0; offset < len(chunk.Code); offset += compiler.Operation(chunk.Code[offset]).Size() {
+		c.ownLines[chunk.Lines[offset]] = true
+		if o := compiler.Operation(chunk.Code[offset]); o == compiler.OperationJumpIfFalse || o == compiler.OperationJumpIfNotNil {
+			line := chunk.Lines[offset]
+			c.branches[offset] = &branch{line, 0, 0}
+			c.lineJumps[line] = append(c.lineJumps[line], offset)
+		}
+	}
+}
+
+func (c *coverage) Hook(v vm.VM) error {
+	chunk := v.Chunk()
+	if c.statements == nil {
+		c.init(chunk)
+	}
+	offset := v.Offset()
+	// A line is executed once each time control enters it, however many
+	// statements start on it. Control staying on one line, as in a loop
+	// written on a single line, counts once. Lines without instructions of
+	// their own, like a lone '{', count when one of their statements starts.
+	if line := chunk.Lines[offset]; line != c.line {
+		c.line = line
+		if _, ok := c.lines[line]; ok {
+			c.lines[line]++
+		}
+	}
+	for _, line := range c.statements[offset] {
+		if !c.ownLines[line] {
+			c.lines[line]++
+		}
+	}
+	if b, ok := c.branches[offset]; ok {
+		if isTaken(compiler.Operation(chunk.Code[offset]), v.Peek(0)) {
+			b.taken++
+		} else {
+			b.skip++
+		}
+	}
+	return nil
+}
+
+// isTaken reports whether the conditional jump o jumps for the value on top
+// of the stack.
+func isTaken(o compiler.Operation, top compiler.Value) bool {
+	if o == compiler.OperationJumpIfNotNil {
+		return !top.IsNil()
+	}
+	return top.IsFalsey()
+}
+
+func (c *coverage) sortedLines() []int {
+	lines := make([]int, 0, len(c.lines))
+	for line := range c.lines {
+		lines = append(lines, line)
+	}
+	sort.Ints(lines)
+	return lines
+}
+
+func (c *coverage) counts() (int, int, int, int) {
+	var lines, coveredLines, branches, coveredBranches int
+	for _, hits := range c.lines {
+		lines++
+		if hits > 0 {
+			coveredLines++
+		}
+	}
+	for _, b := range c.branches {
+		branches += 2
+		if b.skip > 0 {
+			coveredBranches++
+		}
+		if b.taken > 0 {
+			coveredBranches++
+		}
+	}
+	return lines, coveredLines, branches, coveredBranches
+}
+
+func percent(covered, total int) float64 {
+	if total == 0 {
+		return 100
+	}
+	return float64(covered) * 100 / float64(total)
+}
+
+func (c *coverage) Summary(w io.Writer) error {
+	lines, coveredLines, branches, coveredBranches := c.counts()
+	if _, err := fmt.Fprintf(w, "%s: lines %d/%d (%.1f%%), branches %d/%d (%.1f%%)\n", c.name,
+		coveredLines, lines, percent(coveredLines, lines), coveredBranches, branches, percent(coveredBranches, branches)); err != nil {
+		return err
+	}
+	uncovered := make([]string, 0)
+	for _, line := range c.sortedLines() {
+		if c.lines[line] == 0 {
+			uncovered = append(uncovered, fmt.Sprint(line))
+		}
+	}
+	if len(uncovered) > 0 {
+		_, err := fmt.Fprintf(w, "uncovered lines: %s\n", strings.Join(uncovered, ", "))
+		return err
+	}
+	return nil
+}
+
+func (c *coverage) WriteLCOV(w io.Writer) error {
+	var sb strings.Builder
+	sb.WriteString("TN:\n")
+	sb.WriteString(fmt.Sprintf("SF:%s\n", c.name))
+	lines, coveredLines, branches, coveredBranches := c.counts()
+	jumpLines := make([]int, 0, len(c.lineJumps))
+	for line := range c.lineJumps {
+		jumpLines = append(jumpLines, line)
+	}
+	sort.Ints(jumpLines)
+	for _, line := range jumpLines {
+		for i, offset := range c.lineJumps[line] {
+			b := c.branches[offset]
+			reached := b.skip+b.taken > 0
+			for j, n := range []int{b.skip, b.taken} {
+				count := "-"
+				if reached {
+					count = fmt.Sprint(n)
+				}
+				sb.WriteString(fmt.Sprintf("BRDA:%d,%d,%d,%s\n", line, i, j, count))
+			}
+		}
+	}
+	sb.WriteString(fmt.Sprintf("BRF:%d\nBRH:%d\n", branches, coveredBranches))
+	for _, line := range c.sortedLines() {
+		sb.WriteString(fmt.Sprintf("DA:%d,%d\n", line, c.lines[line]))
+	}
+	sb.WriteString(fmt.Sprintf("LF:%d\nLH:%d\n", lines, coveredLines))
+	sb.WriteString("end_of_record\n")
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+type htmlLine struct {
+	Number   int
+	Text     string
+	Class    string
+	Hits     string
+	Branches string
+}
+
+var page = template.Must(template.New("coverage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Name}} coverage</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; font-family: monospace; }
+td { padding: 0 8px; white-space: pre; }
+td.number, td.hits { text-align: right; color: #888; }
+tr.covered td.text { background: #d4f7d4; }
+tr.uncovered td.text { background: #f7d4d4; }
+tr.partial td.text { background: #f7f0c8; }
+</style>
+</head>
+<body>
+<h1>{{.Name}}</h1>
+<p>{{.Summary}}</p>
+<table>
+{{range .Lines}}<tr class="{{.Class}}"><td class="number">{{.Number}}</td><td class="hits">{{.Hits}}</td><td class="text">{{.Text}}</td><td class="hits">{{.Branches}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+func (c *coverage) WriteHTML(w io.Writer) error {
+	lines := make([]htmlLine, 0, len(c.source))
+	for i, text := range c.source {
+		l := htmlLine{i + 1, text, "", "", ""}
+		if hits, ok := c.lines[l.Number]; ok {
+			l.Hits = fmt.Sprint(hits)
+			l.Class = "covered"
+			if hits == 0 {
+				l.Class = "uncovered"
+			}
+		}
+		if offsets := c.lineJumps[l.Number]; len(offsets) > 0 {
+			covered := 0
+			for _, offset := range offsets {
+				if c.branches[offset].skip > 0 {
+					covered++
+				}
+				if c.branches[offset].taken > 0 {
+					covered++
+				}
+			}
+			l.Branches = fmt.Sprintf("%d/%d branches", covered, 2*len(offsets))
+			if l.Class == "covered" && covered < 2*len(offsets) {
+				l.Class = "partial"
+			}
+		}
+		lines = append(lines, l)
+	}
+	var summary strings.Builder
+	if err := c.Summary(&summary); err != nil {
+		return err
+	}
+	return page.Execute(w, map[string]any{"Name": c.name, "Summary": summary.String(), "Lines": lines})
+}
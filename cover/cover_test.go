@@ -0,0 +1,103 @@
+package cover
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+	"github.com/lukibw/abc/vm"
+)
+
+const source = `var a = 0;
+for (var i = 0; i < 3; i++) {
+  if (i == 1) { a = a + 1; a = a * 2; }
+}
+if (a > 5) print a;
+var b = nil ?? 1;
+while (b < 3)
+{
+  b++;
+}
+`
+
+func cover(t *testing.T, source string) Coverage {
+	t.Helper()
+	c := New("main.abc", []byte(source))
+	v, err := vm.New(compiler.New(scanner.New([]byte(source))), vm.WithHook(c.Hook), vm.WithoutPrint())
+	if err != nil {
+		t.Fatalf("vm.New() error = %v", err)
+	}
+	if err = v.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return c
+}
+
+func TestWriteLCOV(t *testing.T) {
+	var lcov strings.Builder
+	if err := cover(t, source).WriteLCOV(&lcov); err != nil {
+		t.Fatalf("WriteLCOV() error = %v", err)
+	}
+	// The loop header runs once per iteration plus the final check, the
+	// one-line block once per iteration however many statements it has.
+	want := `TN:
+SF:main.abc
+BRDA:2,0,0,3
+BRDA:2,0,1,1
+BRDA:3,0,0,1
+BRDA:3,0,1,2
+BRDA:5,0,0,0
+BRDA:5,0,1,1
+BRDA:6,0,0,1
+BRDA:6,0,1,0
+BRDA:7,0,0,2
+BRDA:7,0,1,1
+BRF:10
+BRH:8
+DA:1,1
+DA:2,4
+DA:3,3
+DA:5,1
+DA:6,1
+DA:7,3
+DA:8,2
+DA:9,2
+LF:8
+LH:8
+end_of_record
+`
+	if lcov.String() != want {
+		t.Fatalf("WriteLCOV() =\n%s\nwant\n%s", lcov.String(), want)
+	}
+}
+
+func TestSummary(t *testing.T) {
+	c := cover(t, "var a = 1;\nif (a > 1) {\n  print a;\n}\n")
+	var summary strings.Builder
+	if err := c.Summary(&summary); err != nil {
+		t.Fatalf("Summary() error = %v", err)
+	}
+	want := "main.abc: lines 2/3 (66.7%), branches 1/2 (50.0%)\nuncovered lines: 3\n"
+	if summary.String() != want {
+		t.Fatalf("Summary() = %q, want %q", summary.String(), want)
+	}
+}
+
+func TestWriteHTML(t *testing.T) {
+	var html strings.Builder
+	if err := cover(t, source).WriteHTML(&html); err != nil {
+		t.Fatalf("WriteHTML() error = %v", err)
+	}
+	for _, want := range []string{
+		"<p>main.abc: lines 8/8 (100.0%), branches 8/10 (80.0%)\n</p>",
+		`<tr class="covered"><td class="number">1</td><td class="hits">1</td><td class="text">var a = 0;</td><td class="hits"></td></tr>`,
+		`<tr class="covered"><td class="number">2</td><td class="hits">4</td><td class="text">for (var i = 0; i &lt; 3; i&#43;&#43;) {</td><td class="hits">2/2 branches</td></tr>`,
+		`<tr class=""><td class="number">4</td><td class="hits"></td><td class="text">}</td><td class="hits"></td></tr>`,
+		`<tr class="partial"><td class="number">5</td><td class="hits">1</td><td class="text">if (a &gt; 5) print a;</td><td class="hits">1/2 branches</td></tr>`,
+	} {
+		if !strings.Contains(html.String(), want) {
+			t.Errorf("WriteHTML() does not contain %s", want)
+		}
+	}
+}
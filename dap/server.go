@@ -0,0 +1,367 @@
+package dap
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+	"github.com/lukibw/abc/transport"
+	"github.com/lukibw/abc/vm"
+)
+
+const (
+	threadID         = 1
+	localsReference  = 1
+	globalsReference = 2
+)
+
+type resumption int
+
+const (
+	resumeContinue resumption = iota
+	resumeStep
+	resumeStop
+)
+
+var errTerminated = errors.New("dap: terminated")
+
+type Server interface {
+	Run() error
+}
+
+func New(r io.Reader, w io.Writer) Server {
+	return &server{
+		conn:        transport.New(r, w),
+		breakpoints: make(map[int]bool),
+		resume:      make(chan resumption, 1),
+		done:        make(chan struct{}),
+	}
+}
+
+type server struct {
+	conn        transport.Conn
+	seqMutex    sync.Mutex
+	seq         int
+	program     string
+	vm          vm.VM
+	statements  map[int]int
+	started     bool
+	mutex       sync.Mutex
+	breakpoints map[int]bool
+	// requested holds the breakpoints as last set by the client, the ones
+	// set before launch are verified once the program is compiled.
+	requested    []breakpoint
+	breakpointID int
+	entry        bool
+	stepping     bool
+	pausing      bool
+	terminating  bool
+	paused       bool
+	line         int
+	resume       chan resumption
+	done         chan struct{}
+}
+
+func (s *server) event(name string, body any) error {
+	s.seqMutex.Lock()
+	defer s.seqMutex.Unlock()
+	s.seq++
+	return s.conn.Write(&event{s.seq, "event", name, body})
+}
+
+func (s *server) respond(r *request, body any, err error) error {
+	s.seqMutex.Lock()
+	defer s.seqMutex.Unlock()
+	s.seq++
+	res := &response{s.seq, "response", r.Seq, err == nil, r.Command, "", body}
+	if err != nil {
+		res.Message = err.Error()
+		res.Body = nil
+	}
+	return s.conn.Write(res)
+}
+
+func (s *server) print(v compiler.Value) error {
+	return s.event("output", map[string]any{"category": "stdout", "output": v.String() + "\n"})
+}
+
+func (s *server) hook(v vm.VM) error {
+	s.mutex.Lock()
+	if s.terminating {
+		s.mutex.Unlock()
+		return errTerminated
+	}
+	line, ok := s.statements[v.Offset()]
+	reason := ""
+	switch {
+	case !ok:
+	case s.entry:
+		reason = "entry"
+	case s.stepping:
+		reason = "step"
+	case s.pausing:
+		reason = "pause"
+	case s.breakpoints[line]:
+		reason = "breakpoint"
+	}
+	if reason == "" {
+		s.mutex.Unlock()
+		return nil
+	}
+	s.entry, s.stepping, s.pausing = false, false, false
+	s.paused = true
+	s.line = line
+	s.mutex.Unlock()
+	if err := s.event("stopped", map[string]any{"reason": reason, "threadId": threadID, "allThreadsStopped": true}); err != nil {
+		s.mutex.Lock()
+		s.paused = false
+		s.mutex.Unlock()
+		return err
+	}
+	switch <-s.resume {
+	case resumeStep:
+		s.mutex.Lock()
+		s.stepping = true
+		s.mutex.Unlock()
+	case resumeStop:
+		return errTerminated
+	}
+	return nil
+}
+
+func (s *server) continueWith(r resumption) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if r == resumeStop {
+		s.terminating = true
+	}
+	if s.paused {
+		s.paused = false
+		s.resume <- r
+	}
+}
+
+func (s *server) execute() {
+	defer close(s.done)
+	exitCode := 0
+	if err := s.vm.Run(); err != nil && !errors.Is(err, errTerminated) {
+		s.event("output", map[string]any{"category": "stderr", "output": err.Error() + "\n"})
+		exitCode = 1
+	}
+	s.event("exited", map[string]any{"exitCode": exitCode})
+	s.event("terminated", nil)
+}
+
+func (s *server) stop() {
+	s.continueWith(resumeStop)
+	if s.started {
+		<-s.done
+	}
+}
+
+func (s *server) launch(args *launchArguments) error {
+	content, err := os.ReadFile(args.Program)
+	if err != nil {
+		return err
+	}
+	v, err := vm.New(compiler.New(scanner.New(content)), vm.WithHook(s.hook), vm.WithPrint(s.print))
+	if err != nil {
+		return err
+	}
+	s.program = args.Program
+	s.vm = v
+	s.statements = v.Chunk().Statements()
+	s.entry = args.StopOnEntry
+	return nil
+}
+
+// verifyBreakpoints enables the requested breakpoints on lines with code,
+// it returns the ones it verified for the first time.
+func (s *server) verifyBreakpoints() []breakpoint {
+	s.breakpoints = make(map[int]bool)
+	changed := make([]breakpoint, 0)
+	for i, b := range s.requested {
+		if s.vm == nil || len(s.vm.Chunk().LineOffsets[b.Line]) == 0 {
+			continue
+		}
+		s.breakpoints[b.Line] = true
+		if !b.Verified {
+			s.requested[i].Verified = true
+			changed = append(changed, s.requested[i])
+		}
+	}
+	return changed
+}
+
+func (s *server) setBreakpoints(args *setBreakpointsArguments) any {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requested = make([]breakpoint, 0, len(args.Breakpoints))
+	for _, b := range args.Breakpoints {
+		s.breakpointID++
+		s.requested = append(s.requested, breakpoint{s.breakpointID, false, b.Line})
+	}
+	s.verifyBreakpoints()
+	return map[string]any{"breakpoints": append([]breakpoint(nil), s.requested...)}
+}
+
+func (s *server) stackTrace() any {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	frames := make([]stackFrame, 0, 1)
+	if s.paused {
+		frames = append(frames, stackFrame{1, "main", source{filepath.Base(s.program), s.program}, s.line, 1})
+	}
+	return map[string]any{"stackFrames": frames, "totalFrames": len(frames)}
+}
+
+func display(v compiler.Value) string {
+	if v.IsString() {
+		return strconv.Quote(v.AsString())
+	}
+	return v.String()
+}
+
+func (s *server) variables(args *variablesArguments) any {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	variables := make([]variable, 0)
+	if !s.paused {
+		return map[string]any{"variables": variables}
+	}
+	switch args.VariablesReference {
+	case localsReference:
+		stack := s.vm.Stack()
+		for _, l := range s.vm.Chunk().LocalsAt(s.vm.Offset()) {
+			if l.Slot < len(stack) {
+				variables = append(variables, variable{l.Name, display(stack[l.Slot]), 0})
+			}
+		}
+	case globalsReference:
+		for name, value := range s.vm.Globals() {
+			variables = append(variables, variable{name, display(value), 0})
+		}
+		sort.Slice(variables, func(i, j int) bool {
+			return variables[i].Name < variables[j].Name
+		})
+	}
+	return map[string]any{"variables": variables}
+}
+
+func decode[T any](args json.RawMessage) (*T, error) {
+	v := new(T)
+	if len(args) == 0 {
+		return v, nil
+	}
+	if err := json.Unmarshal(args, v); err != nil {
+		return nil, err
+	}
+	return v, nil
+}
+
+func (s *server) handle(r *request) (any, error) {
+	switch r.Command {
+	case "initialize":
+		return map[string]any{"supportsConfigurationDoneRequest": true, "supportsTerminateRequest": true}, nil
+	case "launch":
+		args, err := decode[launchArguments](r.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.launch(args)
+	case "setBreakpoints":
+		args, err := decode[setBreakpointsArguments](r.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return s.setBreakpoints(args), nil
+	case "configurationDone":
+		if s.vm == nil {
+			return nil, fmt.Errorf("no program launched")
+		}
+		return nil, nil
+	case "threads":
+		return map[string]any{"threads": []thread{{threadID, "main"}}}, nil
+	case "stackTrace":
+		return s.stackTrace(), nil
+	case "scopes":
+		return map[string]any{"scopes": []scope{{"Locals", localsReference, false}, {"Globals", globalsReference, false}}}, nil
+	case "variables":
+		args, err := decode[variablesArguments](r.Arguments)
+		if err != nil {
+			return nil, err
+		}
+		return s.variables(args), nil
+	case "continue":
+		return map[string]any{"allThreadsContinued": true}, nil
+	case "next", "stepIn", "stepOut":
+		return nil, nil
+	case "pause":
+		s.mutex.Lock()
+		s.pausing = true
+		s.mutex.Unlock()
+		return nil, nil
+	case "terminate", "disconnect":
+		s.stop()
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unsupported command '%s'", r.Command)
+	}
+}
+
+func (s *server) Run() error {
+	for {
+		content, err := s.conn.Read()
+		if err != nil {
+			s.stop()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var r request
+		if err = json.Unmarshal(content, &r); err != nil {
+			// A malformed message has no sequence number to answer, the
+			// client is told about it and the session goes on.
+			if err = s.event("output", map[string]any{"category": "console", "output": fmt.Sprintf("dap: invalid message: %v\n", err)}); err != nil {
+				return err
+			}
+			continue
+		}
+		body, err := s.handle(&r)
+		if err = s.respond(&r, body, err); err != nil {
+			return err
+		}
+		switch {
+		case r.Command == "launch" && s.vm != nil:
+			s.mutex.Lock()
+			changed := s.verifyBreakpoints()
+			s.mutex.Unlock()
+			for _, b := range changed {
+				if err = s.event("breakpoint", map[string]any{"reason": "changed", "breakpoint": b}); err != nil {
+					return err
+				}
+			}
+			if err = s.event("initialized", nil); err != nil {
+				return err
+			}
+		case r.Command == "configurationDone" && s.vm != nil && !s.started:
+			s.started = true
+			go s.execute()
+		case r.Command == "continue":
+			s.continueWith(resumeContinue)
+		case r.Command == "next" || r.Command == "stepIn" || r.Command == "stepOut":
+			s.continueWith(resumeStep)
+		case r.Command == "disconnect":
+			return nil
+		}
+	}
+}
@@ -0,0 +1,244 @@
+package dap
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/lukibw/abc/transport"
+)
+
+type message struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Command    string          `json:"command"`
+	Message    string          `json:"message"`
+	Event      string          `json:"event"`
+	Body       json.RawMessage `json:"body"`
+}
+
+// client is a scripted DAP client, it keeps the messages it read but did
+// not wait for yet, as events of the running program and responses may
+// arrive in any order.
+type client struct {
+	t        *testing.T
+	w        io.Writer
+	conn     transport.Conn
+	seq      int
+	messages chan *message
+	pending  []*message
+}
+
+func start(t *testing.T) *client {
+	t.Helper()
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- New(serverReader, serverWriter).Run()
+		serverWriter.Close()
+	}()
+	c := &client{t: t, w: clientWriter, conn: transport.New(clientReader, clientWriter), messages: make(chan *message)}
+	go func() {
+		defer close(c.messages)
+		for {
+			content, err := c.conn.Read()
+			if err != nil {
+				return
+			}
+			var m message
+			if err = json.Unmarshal(content, &m); err != nil {
+				t.Errorf("unmarshal %s: %v", content, err)
+				return
+			}
+			c.messages <- &m
+		}
+	}()
+	t.Cleanup(func() {
+		clientWriter.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+		for range c.messages {
+		}
+	})
+	return c
+}
+
+func (c *client) send(command string, arguments any) int {
+	c.t.Helper()
+	c.seq++
+	if err := c.conn.Write(map[string]any{"seq": c.seq, "type": "request", "command": command, "arguments": arguments}); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+	return c.seq
+}
+
+// wait returns the first message satisfying ok, failing the test when none
+// arrives in time.
+func (c *client) wait(what string, ok func(*message) bool) *message {
+	c.t.Helper()
+	for i, m := range c.pending {
+		if ok(m) {
+			c.pending = append(c.pending[:i], c.pending[i+1:]...)
+			return m
+		}
+	}
+	timeout := time.After(5 * time.Second)
+	for {
+		select {
+		case m, open := <-c.messages:
+			if !open {
+				c.t.Fatalf("connection closed waiting for %s", what)
+			}
+			if ok(m) {
+				return m
+			}
+			c.pending = append(c.pending, m)
+		case <-timeout:
+			c.t.Fatalf("timeout waiting for %s, got %+v", what, c.pending)
+		}
+	}
+}
+
+// request sends a request, waits for its successful response and decodes
+// its body into body unless it is nil.
+func (c *client) request(command string, arguments any, body any) {
+	c.t.Helper()
+	seq := c.send(command, arguments)
+	m := c.wait("response to "+command, func(m *message) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+	if !m.Success || m.Command != command {
+		c.t.Fatalf("%s failed: %+v", command, m)
+	}
+	if body != nil {
+		if err := json.Unmarshal(m.Body, body); err != nil {
+			c.t.Fatalf("unmarshal %s: %v", m.Body, err)
+		}
+	}
+}
+
+func (c *client) event(name string, body any) {
+	c.t.Helper()
+	m := c.wait(name+" event", func(m *message) bool {
+		return m.Type == "event" && m.Event == name
+	})
+	if body != nil {
+		if err := json.Unmarshal(m.Body, body); err != nil {
+			c.t.Fatalf("unmarshal %s: %v", m.Body, err)
+		}
+	}
+}
+
+func program(t *testing.T, source string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "main.abc")
+	if err := os.WriteFile(path, []byte(source), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestSession(t *testing.T) {
+	path := program(t, "var a = 1;\nvar b = a + 1;\nprint b;\n")
+	c := start(t)
+	c.request("initialize", map[string]any{"adapterID": "abc"}, nil)
+
+	var set struct {
+		Breakpoints []breakpoint `json:"breakpoints"`
+	}
+	c.request("setBreakpoints", map[string]any{
+		"source":      map[string]any{"path": path},
+		"breakpoints": []map[string]any{{"line": 2}, {"line": 9}},
+	}, &set)
+	if len(set.Breakpoints) != 2 || set.Breakpoints[0].Verified || set.Breakpoints[1].Verified {
+		t.Fatalf("breakpoints before launch = %+v, want two unverified", set.Breakpoints)
+	}
+
+	c.request("launch", map[string]any{"program": path}, nil)
+	var changed struct {
+		Reason     string     `json:"reason"`
+		Breakpoint breakpoint `json:"breakpoint"`
+	}
+	c.event("breakpoint", &changed)
+	if want := (breakpoint{set.Breakpoints[0].ID, true, 2}); changed.Reason != "changed" || changed.Breakpoint != want {
+		t.Errorf("breakpoint event = %+v, want %+v changed", changed, want)
+	}
+	c.event("initialized", nil)
+	c.request("configurationDone", nil, nil)
+
+	var stopped struct {
+		Reason string `json:"reason"`
+	}
+	c.event("stopped", &stopped)
+	if stopped.Reason != "breakpoint" {
+		t.Errorf("stopped reason = %q, want breakpoint", stopped.Reason)
+	}
+	var trace struct {
+		StackFrames []stackFrame `json:"stackFrames"`
+	}
+	c.request("stackTrace", map[string]any{"threadId": threadID}, &trace)
+	if len(trace.StackFrames) != 1 || trace.StackFrames[0].Line != 2 {
+		t.Errorf("stack frames = %+v, want one on line 2", trace.StackFrames)
+	}
+	var globals struct {
+		Variables []variable `json:"variables"`
+	}
+	c.request("variables", map[string]any{"variablesReference": globalsReference}, &globals)
+	if len(globals.Variables) != 1 || globals.Variables[0] != (variable{"a", "1", 0}) {
+		t.Errorf("globals = %+v, want a = 1", globals.Variables)
+	}
+
+	c.request("continue", map[string]any{"threadId": threadID}, nil)
+	var output struct {
+		Output string `json:"output"`
+	}
+	c.event("output", &output)
+	if output.Output != "2\n" {
+		t.Errorf("output = %q, want %q", output.Output, "2\n")
+	}
+	var exited struct {
+		ExitCode int `json:"exitCode"`
+	}
+	c.event("exited", &exited)
+	if exited.ExitCode != 0 {
+		t.Errorf("exit code = %d, want 0", exited.ExitCode)
+	}
+	c.event("terminated", nil)
+	c.request("disconnect", nil, nil)
+}
+
+func TestMalformedMessage(t *testing.T) {
+	c := start(t)
+	frame := "{not json"
+	if _, err := fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n%s", len(frame), frame); err != nil {
+		t.Fatal(err)
+	}
+	var output struct {
+		Category string `json:"category"`
+	}
+	c.event("output", &output)
+	if output.Category != "console" {
+		t.Errorf("output category = %q, want console", output.Category)
+	}
+	c.request("initialize", nil, nil)
+	c.request("threads", nil, nil)
+}
+
+func TestLaunchMissingProgram(t *testing.T) {
+	c := start(t)
+	seq := c.send("launch", map[string]any{"program": filepath.Join(t.TempDir(), "missing.abc")})
+	m := c.wait("launch response", func(m *message) bool {
+		return m.Type == "response" && m.RequestSeq == seq
+	})
+	if m.Success || m.Message == "" {
+		t.Errorf("launch response = %+v, want failure with a message", m)
+	}
+}
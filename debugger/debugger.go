@@ -0,0 +1,141 @@
+package debugger
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/vm"
+)
+
+var ErrQuit = errors.New("debugger: quit")
+
+const help = `commands:
+  b, break <line>    stop at every statement on the line
+  d, delete <line>   remove the breakpoint on the line
+  s, step            run until the next statement
+  n, next            run until the next statement
+  c, continue        run until the next breakpoint
+  p, stack           print the value stack
+  l, locals          print the local variables in scope
+  g, globals         print the global variables
+  q, quit            stop the program
+`
+
+type Debugger interface {
+	Hook(v vm.VM) error
+}
+
+func New(source []byte, in io.Reader, out io.Writer) Debugger {
+	return &debugger{strings.Split(string(source), "\n"), bufio.NewScanner(in), out, make(map[int]bool), true, nil}
+}
+
+type debugger struct {
+	source      []string
+	in          *bufio.Scanner
+	out         io.Writer
+	breakpoints map[int]bool
+	stepping    bool
+	statements  map[int]int
+}
+
+func (d *debugger) Hook(v vm.VM) error {
+	if d.statements == nil {
+		d.statements = v.Chunk().Statements()
+	}
+	line, ok := d.statements[v.Offset()]
+	if !ok {
+		return nil
+	}
+	if !d.stepping && !d.breakpoints[line] {
+		return nil
+	}
+	d.stepping = false
+	if line <= len(d.source) {
+		fmt.Fprintf(d.out, "%d: %s\n", line, strings.TrimSpace(d.source[line-1]))
+	}
+	return d.prompt(v)
+}
+
+func (d *debugger) prompt(v vm.VM) error {
+	for {
+		fmt.Fprint(d.out, "(abc) ")
+		if !d.in.Scan() {
+			if err := d.in.Err(); err != nil {
+				return err
+			}
+			return ErrQuit
+		}
+		fields := strings.Fields(d.in.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		switch fields[0] {
+		case "b", "break":
+			d.setBreakpoint(v.Chunk(), fields[1:], true)
+		case "d", "delete":
+			d.setBreakpoint(v.Chunk(), fields[1:], false)
+		case "s", "step", "n", "next":
+			d.stepping = true
+			return nil
+		case "c", "continue":
+			return nil
+		case "p", "stack":
+			for i, value := range v.Stack() {
+				fmt.Fprintf(d.out, "%d: %s\n", i, value)
+			}
+		case "l", "locals":
+			stack := v.Stack()
+			for _, l := range v.Chunk().LocalsAt(v.Offset()) {
+				if l.Slot < len(stack) {
+					fmt.Fprintf(d.out, "%s = %s\n", l.Name, stack[l.Slot])
+				}
+			}
+		case "g", "globals":
+			d.printGlobals(v.Globals())
+		case "q", "quit":
+			return ErrQuit
+		case "h", "help":
+			fmt.Fprint(d.out, help)
+		default:
+			fmt.Fprintf(d.out, "unknown command '%s', type 'help' for a list\n", fields[0])
+		}
+	}
+}
+
+func (d *debugger) setBreakpoint(chunk *compiler.Chunk, args []string, enabled bool) {
+	if len(args) != 1 {
+		fmt.Fprintln(d.out, "missing line number")
+		return
+	}
+	line, err := strconv.Atoi(args[0])
+	if err != nil {
+		fmt.Fprintf(d.out, "invalid line number '%s'\n", args[0])
+		return
+	}
+	if !enabled {
+		delete(d.breakpoints, line)
+		return
+	}
+	if len(chunk.LineOffsets[line]) > 0 {
+		d.breakpoints[line] = true
+		return
+	}
+	fmt.Fprintf(d.out, "no statement on line %d\n", line)
+}
+
+func (d *debugger) printGlobals(globals map[string]compiler.Value) {
+	names := make([]string, 0, len(globals))
+	for name := range globals {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(d.out, "%s = %s\n", name, globals[name])
+	}
+}
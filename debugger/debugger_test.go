@@ -0,0 +1,114 @@
+package debugger
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+	"github.com/lukibw/abc/vm"
+)
+
+const source = `var total = 0;
+for (var i = 0; i < 3; i++) {
+  total = total + i;
+}
+{
+  var last = total * 2;
+  print last;
+}
+`
+
+// debug runs source under the debugger reading the commands and returns
+// the transcript, the output of the program and the error of the run.
+func debug(t *testing.T, commands string) (string, string, error) {
+	t.Helper()
+	var transcript, output strings.Builder
+	d := New([]byte(source), strings.NewReader(commands), &transcript)
+	v, err := vm.New(compiler.New(scanner.New([]byte(source))), vm.WithHook(d.Hook), vm.WithOutput(&output))
+	if err != nil {
+		t.Fatalf("vm.New() error = %v", err)
+	}
+	err = v.Run()
+	return transcript.String(), output.String(), err
+}
+
+func TestSession(t *testing.T) {
+	tests := []struct {
+		name       string
+		commands   string
+		transcript string
+		output     string
+		err        error
+	}{
+		{
+			"breakpoint and continue",
+			"b 3\nc\nl\nc\ng\nd 3\nc\n",
+			"1: var total = 0;\n" +
+				"(abc) (abc) 3: total = total + i;\n" +
+				"(abc) i = 0\n" +
+				"(abc) 3: total = total + i;\n" +
+				"(abc) total = 0\n" +
+				"(abc) (abc) ",
+			"6\n",
+			nil,
+		},
+		{
+			"step and next",
+			"b 6\nc\nn\nl\np\ns\n",
+			"1: var total = 0;\n" +
+				"(abc) (abc) 6: var last = total * 2;\n" +
+				"(abc) 7: print last;\n" +
+				"(abc) last = 6\n" +
+				"(abc) 0: 6\n" +
+				"(abc) ",
+			"6\n",
+			nil,
+		},
+		{
+			"step from the start",
+			"s\ns\ns\nq\n",
+			"1: var total = 0;\n" +
+				"(abc) 2: for (var i = 0; i < 3; i++) {\n" +
+				"(abc) 3: total = total + i;\n" +
+				"(abc) 3: total = total + i;\n" +
+				"(abc) ",
+			"",
+			ErrQuit,
+		},
+		{
+			"invalid commands",
+			"b\nb x\nb 9\nfoo\n\nc\n",
+			"1: var total = 0;\n" +
+				"(abc) missing line number\n" +
+				"(abc) invalid line number 'x'\n" +
+				"(abc) no statement on line 9\n" +
+				"(abc) unknown command 'foo', type 'help' for a list\n" +
+				"(abc) (abc) ",
+			"6\n",
+			nil,
+		},
+		{
+			"end of input",
+			"b 7\n",
+			"1: var total = 0;\n(abc) (abc) ",
+			"",
+			ErrQuit,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			transcript, output, err := debug(t, tt.commands)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("Run() error = %v, want %v", err, tt.err)
+			}
+			if transcript != tt.transcript {
+				t.Errorf("transcript = %q, want %q", transcript, tt.transcript)
+			}
+			if output != tt.output {
+				t.Errorf("output = %q, want %q", output, tt.output)
+			}
+		})
+	}
+}
@@ -0,0 +1,188 @@
+package golden
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+	"github.com/lukibw/abc/vm"
+)
+
+const (
+	expectOutput       = "// expect: "
+	expectCompileError = "// expect compile error: "
+	expectRuntimeError = "// expect runtime error: "
+)
+
+type expectation struct {
+	output       []string
+	compileError string
+	compileLine  int
+	runtimeError string
+	runtimeLine  int
+}
+
+func parse(source []byte) *expectation {
+	e := &expectation{output: make([]string, 0)}
+	s := bufio.NewScanner(bytes.NewReader(source))
+	for line := 1; s.Scan(); line++ {
+		text := s.Text()
+		if i := strings.Index(text, expectOutput); i != -1 {
+			e.output = append(e.output, strings.TrimSpace(text[i+len(expectOutput):]))
+		} else if i := strings.Index(text, expectCompileError); i != -1 {
+			e.compileError = strings.TrimSpace(text[i+len(expectCompileError):])
+			e.compileLine = line
+		} else if i := strings.Index(text, expectRuntimeError); i != -1 {
+			e.runtimeError = strings.TrimSpace(text[i+len(expectRuntimeError):])
+			e.runtimeLine = line
+		}
+	}
+	return e
+}
+
+func compileError(err error) (string, int, bool) {
+	var compilerErr *compiler.Error
+	var scannerErr *scanner.Error
+	switch {
+	case errors.As(err, &compilerErr):
+		return compilerErr.Kind.String(), compilerErr.Token.Line, true
+	case errors.As(err, &scannerErr):
+		return scannerErr.Kind.String(), scannerErr.Line, true
+	default:
+		return "", 0, false
+	}
+}
+
+func check(source []byte) ([]string, error) {
+	e := parse(source)
+	failures := make([]string, 0)
+	var output strings.Builder
+	v, err := vm.New(compiler.New(scanner.New(source)), vm.WithOutput(&output))
+	if err != nil {
+		message, line, ok := compileError(err)
+		switch {
+		case !ok:
+			return nil, err
+		case e.compileError == "":
+			failures = append(failures, fmt.Sprintf("unexpected compile error: %s", err))
+		case e.compileError != message || e.compileLine != line:
+			failures = append(failures, fmt.Sprintf("expected compile error '%s' on line %d, got: %s", e.compileError, e.compileLine, err))
+		}
+		return failures, nil
+	}
+	if e.compileError != "" {
+		failures = append(failures, fmt.Sprintf("expected compile error '%s' on line %d", e.compileError, e.compileLine))
+	}
+	err = v.Run()
+	var runtimeErr *vm.Error
+	switch {
+	case err != nil && !errors.As(err, &runtimeErr):
+		return nil, err
+	case err != nil && e.runtimeError == "":
+		failures = append(failures, fmt.Sprintf("unexpected runtime error: %s", err))
+	case err != nil && (e.runtimeError != runtimeErr.Kind.String() || e.runtimeLine != runtimeErr.Line):
+		failures = append(failures, fmt.Sprintf("expected runtime error '%s' on line %d, got: %s", e.runtimeError, e.runtimeLine, err))
+	case err == nil && e.runtimeError != "":
+		failures = append(failures, fmt.Sprintf("expected runtime error '%s' on line %d", e.runtimeError, e.runtimeLine))
+	}
+	lines := strings.Split(output.String(), "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if d := diff(e.output, lines); len(d) > 0 {
+		failures = append(failures, "output differs (-expected +actual):")
+		failures = append(failures, d...)
+	}
+	return failures, nil
+}
+
+// diff returns the lines removed from and added to expected to get actual,
+// or nothing when they are equal.
+func diff(expected, actual []string) []string {
+	lcs := make([][]int, len(expected)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			switch {
+			case expected[i] == actual[j]:
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			case lcs[i+1][j] >= lcs[i][j+1]:
+				lcs[i][j] = lcs[i+1][j]
+			default:
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+	lines := make([]string, 0)
+	changed := false
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			lines = append(lines, "  "+expected[i])
+			i++
+			j++
+		case j == len(actual) || (i < len(expected) && lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "- "+expected[i])
+			changed = true
+			i++
+		default:
+			lines = append(lines, "+ "+actual[j])
+			changed = true
+			j++
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return lines
+}
+
+// Run checks every script in dir against its expectation comments, reports
+// the results to w and tells whether all of them passed.
+func Run(dir string, w io.Writer) (bool, error) {
+	paths := make([]string, 0)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && filepath.Ext(path) == ".abc" {
+			paths = append(paths, path)
+		}
+		return err
+	})
+	if err != nil {
+		return false, err
+	}
+	sort.Strings(paths)
+	failed := 0
+	for _, path := range paths {
+		source, err := os.ReadFile(path)
+		if err != nil {
+			return false, err
+		}
+		failures, err := check(source)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(failures) == 0 {
+			fmt.Fprintf(w, "PASS %s\n", path)
+			continue
+		}
+		failed++
+		fmt.Fprintf(w, "FAIL %s\n", path)
+		for _, f := range failures {
+			fmt.Fprintf(w, "    %s\n", f)
+		}
+	}
+	fmt.Fprintf(w, "%d passed, %d failed\n", len(paths)-failed, failed)
+	return failed == 0, nil
+}
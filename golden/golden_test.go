@@ -0,0 +1,51 @@
+package golden
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		pass   bool
+	}{
+		{"output", "print 1; // expect: 1\n", true},
+		{"output with trailing space", "print 1; // expect: 1  \r\n", true},
+		{"wrong output", "print 1; // expect: 2\n", false},
+		{"runtime error", "var a = 1;\nprint a / 0; // expect runtime error: division by zero\n", true},
+		{"runtime error on other line", "// expect runtime error: division by zero\nprint 1 / 0;\n", false},
+		{"missing runtime error", "print 1; // expect runtime error: division by zero\n", false},
+		{"compile error", "break; // expect compile error: cannot use 'break' outside of a loop\n", true},
+		{"compile error on other line", "// expect compile error: cannot use 'break' outside of a loop\nbreak;\n", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			failures, err := check([]byte(tt.source))
+			if err != nil {
+				t.Fatalf("check() error = %v", err)
+			}
+			if pass := len(failures) == 0; pass != tt.pass {
+				t.Fatalf("check() failures = %q, want pass = %v", failures, tt.pass)
+			}
+		})
+	}
+}
+
+func TestRun(t *testing.T) {
+	var report strings.Builder
+	passed, err := Run("../test", &report)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !passed {
+		failures := make([]string, 0)
+		for _, line := range strings.Split(report.String(), "\n") {
+			if !strings.HasPrefix(line, "PASS ") {
+				failures = append(failures, line)
+			}
+		}
+		t.Fatalf("conformance tests failed:\n%s", strings.Join(failures, "\n"))
+	}
+}
@@ -0,0 +1,74 @@
+package lint
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+)
+
+const directive = "lint:ignore"
+
+// Run compiles the source and returns its warnings ordered by line, leaving
+// out the ones suppressed with a "// lint:ignore [rule...]" comment. The
+// comment applies to its own line, or to the next one when it stands alone.
+func Run(source []byte) ([]*compiler.Warning, error) {
+	c := compiler.New(scanner.New(source))
+	if _, err := c.Run(); err != nil {
+		return nil, err
+	}
+	ignored, err := ignoredRules(source)
+	if err != nil {
+		return nil, err
+	}
+	warnings := make([]*compiler.Warning, 0)
+	for _, w := range c.Warnings() {
+		rules, ok := ignored[w.Token.Line]
+		if ok && (len(rules) == 0 || rules[w.Kind.Rule()]) {
+			continue
+		}
+		warnings = append(warnings, w)
+	}
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return warnings[i].Token.Line < warnings[j].Token.Line
+	})
+	return warnings, nil
+}
+
+func ignoredRules(source []byte) (map[int]map[string]bool, error) {
+	ignored := make(map[int]map[string]bool)
+	s := scanner.NewWithComments(source)
+	// last is the line the previous token ends on, a comment on a later
+	// line stands alone.
+	last := 0
+	for {
+		t, err := s.Token()
+		if err != nil {
+			return nil, err
+		}
+		if t.Kind == scanner.TokenEof {
+			return ignored, nil
+		}
+		if t.Kind != scanner.TokenComment {
+			last = t.Line + strings.Count(t.Lexeme, "\n")
+			continue
+		}
+		comment := strings.TrimSpace(strings.TrimPrefix(t.Lexeme, "//"))
+		rest, ok := strings.CutPrefix(comment, directive)
+		if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+			continue
+		}
+		target := t.Line
+		if last < t.Line {
+			target++
+		}
+		rules := make(map[string]bool)
+		for _, r := range strings.FieldsFunc(rest, func(r rune) bool {
+			return r == ' ' || r == '\t' || r == ','
+		}) {
+			rules[r] = true
+		}
+		ignored[target] = rules
+	}
+}
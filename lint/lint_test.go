@@ -0,0 +1,62 @@
+package lint
+
+import (
+	"testing"
+)
+
+func rules(t *testing.T, source string) []string {
+	t.Helper()
+	warnings, err := Run([]byte(source))
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	rules := make([]string, len(warnings))
+	for i, w := range warnings {
+		rules[i] = w.Kind.Rule()
+	}
+	return rules
+}
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		want   []string
+	}{
+		{"ignore same line", "if (true) print 1; // lint:ignore constant-condition\n", nil},
+		{"ignore next line", "// lint:ignore\nif (true) print 1;\n", nil},
+		{"ignore other rule", "if (true) print 1; // lint:ignore unused-local\n", []string{"constant-condition"}},
+		{"directive prefix", "if (true) print 1; // lint:ignorefoo\n", []string{"constant-condition"}},
+		{"directive in string", "print \"// lint:ignore\"; if (true) print 1;\n", []string{"constant-condition"}},
+		{"comment after string", "if (true) print \"//\"; // lint:ignore\n", nil},
+		{"negated literal", "if (!true) print 1;\n", []string{"constant-condition"}},
+		{"comparison of literals", "while (1 < 2) print 1;\n", []string{"constant-condition"}},
+		{"loop on true", "while (true) { print 1; break; }\n", nil},
+		{"loop on false", "while (false) print 1;\n", []string{"constant-condition"}},
+		{"loop on negated false", "while (!false) break;\n", []string{"constant-condition"}},
+		{"if on true", "if (true) print 1;\n", []string{"constant-condition"}},
+		{"variable condition", "var a = 1; if (a < 2) print 1;\n", nil},
+		{"shadowed earlier global", "var a = 1; { var a = 2; print a; }\n", []string{"shadowed-variable"}},
+		{"shadowed later global", "{ var a = 2; print a; } var a = 1;\n", []string{"shadowed-variable"}},
+		{"unused local", "{ var a = 1; }\n", []string{"unused-local"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := rules(t, tt.source)
+			if len(got) != len(tt.want) {
+				t.Fatalf("rules = %v, want %v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Fatalf("rules = %v, want %v", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestRunCompileError(t *testing.T) {
+	if _, err := Run([]byte("print ;")); err == nil {
+		t.Fatal("Run() error = nil, want compilation error")
+	}
+}
@@ -0,0 +1,141 @@
+package lsp
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+)
+
+type document struct {
+	uri     string
+	symbols []*compiler.Symbol
+	errs    []error
+	lines   []string
+	// encoding is the position encoding agreed with the client, positions
+	// inside the document are counted in code points and converted when
+	// they are exchanged with the client.
+	encoding string
+}
+
+func newDocument(uri, text, encoding string) *document {
+	c := compiler.New(scanner.New([]byte(text)))
+	c.Run()
+	return &document{uri, c.Symbols(), c.Errors(), strings.Split(text, "\n"), encoding}
+}
+
+func utf16Len(r rune) int {
+	if r >= 0x10000 {
+		return 2
+	}
+	return 1
+}
+
+// toClient converts a position counted in code points to the encoding of
+// the client.
+func (d *document) toClient(p position) position {
+	if d.encoding == encodingUTF32 || p.Line >= len(d.lines) {
+		return p
+	}
+	n, i := 0, 0
+	for _, r := range d.lines[p.Line] {
+		if i == p.Character {
+			break
+		}
+		n += utf16Len(r)
+		i++
+	}
+	return position{p.Line, n + p.Character - i}
+}
+
+// fromClient converts a position in the encoding of the client to one
+// counted in code points.
+func (d *document) fromClient(p position) position {
+	if d.encoding == encodingUTF32 || p.Line >= len(d.lines) {
+		return p
+	}
+	n, i := 0, 0
+	for _, r := range d.lines[p.Line] {
+		if n >= p.Character {
+			break
+		}
+		n += utf16Len(r)
+		i++
+	}
+	return position{p.Line, i + p.Character - n}
+}
+
+func (d *document) clientRange(r textRange) textRange {
+	return textRange{d.toClient(r.Start), d.toClient(r.End)}
+}
+
+// tokenRange returns the range of the token, which ends on a later line for
+// strings spanning several lines.
+func tokenRange(t *scanner.Token) textRange {
+	start := position{t.Line - 1, t.Column - 1}
+	i := strings.LastIndexByte(t.Lexeme, '\n')
+	if i == -1 {
+		return textRange{start, position{start.Line, start.Character + utf8.RuneCountInString(t.Lexeme)}}
+	}
+	end := position{start.Line + strings.Count(t.Lexeme, "\n"), utf8.RuneCountInString(t.Lexeme[i+1:])}
+	return textRange{start, end}
+}
+
+func (p position) before(o position) bool {
+	return p.Line < o.Line || (p.Line == o.Line && p.Character <= o.Character)
+}
+
+func contains(t *scanner.Token, p position) bool {
+	r := tokenRange(t)
+	return r.Start.before(p) && p.before(r.End)
+}
+
+func (d *document) location(t *scanner.Token) location {
+	return location{d.uri, d.clientRange(tokenRange(t))}
+}
+
+func (d *document) symbolAt(p position) (*compiler.Symbol, *scanner.Token) {
+	p = d.fromClient(p)
+	for _, s := range d.symbols {
+		if s.Declaration != nil && contains(s.Declaration, p) {
+			return s, s.Declaration
+		}
+		for _, t := range s.References {
+			if contains(t, p) {
+				return s, t
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (d *document) diagnostics() []diagnostic {
+	diagnostics := make([]diagnostic, 0)
+	for _, err := range d.errs {
+		var compilerErr *compiler.Error
+		var scannerErr *scanner.Error
+		switch {
+		case errors.As(err, &compilerErr):
+			diagnostics = append(diagnostics, diagnostic{d.clientRange(tokenRange(compilerErr.Token)), severityError, "abc", compilerErr.Kind.String()})
+		case errors.As(err, &scannerErr):
+			start := position{scannerErr.Line - 1, scannerErr.Column - 1}
+			r := textRange{start, position{start.Line, start.Character + 1}}
+			diagnostics = append(diagnostics, diagnostic{d.clientRange(r), severityError, "abc", scannerErr.Kind.String()})
+		}
+	}
+	return diagnostics
+}
+
+func (d *document) hover(s *compiler.Symbol) string {
+	scope := "local"
+	if s.Global {
+		scope = "global"
+	}
+	if s.Declaration == nil {
+		return fmt.Sprintf("%s variable '%s' is never declared", scope, s.Name)
+	}
+	return fmt.Sprintf("%s variable '%s' declared on line %d", scope, s.Name, s.Declaration.Line)
+}
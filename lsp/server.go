@@ -0,0 +1,242 @@
+package lsp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/lukibw/abc/scanner"
+	"github.com/lukibw/abc/transport"
+)
+
+type Server interface {
+	Run() error
+}
+
+func New(r io.Reader, w io.Writer) Server {
+	return &server{transport.New(r, w), make(map[string]*document), false, encodingUTF16}
+}
+
+type server struct {
+	conn      transport.Conn
+	documents map[string]*document
+	exit      bool
+	encoding  string
+}
+
+func (s *server) notify(method string, params any) error {
+	return s.conn.Write(&notification{"2.0", method, params})
+}
+
+func (s *server) publishDiagnostics(uri string, diagnostics []diagnostic) error {
+	return s.notify("textDocument/publishDiagnostics", &publishDiagnosticsParams{uri, diagnostics})
+}
+
+func (s *server) open(uri, text string) error {
+	d := newDocument(uri, text, s.encoding)
+	s.documents[uri] = d
+	return s.publishDiagnostics(uri, d.diagnostics())
+}
+
+// initialize picks UTF-32 positions, which match the columns of the
+// compiler, when the client offers them and falls back to UTF-16, which
+// every client supports.
+func (s *server) initialize(p *initializeParams) any {
+	s.encoding = encodingUTF16
+	for _, e := range p.Capabilities.General.PositionEncodings {
+		if e == encodingUTF32 {
+			s.encoding = encodingUTF32
+		}
+	}
+	return map[string]any{
+		"capabilities": map[string]any{
+			"positionEncoding":       s.encoding,
+			"textDocumentSync":       syncFull,
+			"definitionProvider":     true,
+			"referencesProvider":     true,
+			"hoverProvider":          true,
+			"documentSymbolProvider": true,
+			"completionProvider":     map[string]any{},
+		},
+		"serverInfo": map[string]any{"name": "abc"},
+	}
+}
+
+func (s *server) definition(p *textDocumentPositionParams) any {
+	d, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbol, _ := d.symbolAt(p.Position)
+	if symbol == nil || symbol.Declaration == nil {
+		return nil
+	}
+	return d.location(symbol.Declaration)
+}
+
+func (s *server) references(p *referenceParams) any {
+	locations := make([]location, 0)
+	d, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return locations
+	}
+	symbol, _ := d.symbolAt(p.Position)
+	if symbol == nil {
+		return locations
+	}
+	if p.Context.IncludeDeclaration && symbol.Declaration != nil {
+		locations = append(locations, d.location(symbol.Declaration))
+	}
+	for _, t := range symbol.References {
+		locations = append(locations, d.location(t))
+	}
+	return locations
+}
+
+func (s *server) hover(p *textDocumentPositionParams) any {
+	d, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return nil
+	}
+	symbol, t := d.symbolAt(p.Position)
+	if symbol == nil {
+		return nil
+	}
+	return &hover{markupContent{"plaintext", d.hover(symbol)}, d.clientRange(tokenRange(t))}
+}
+
+func (s *server) documentSymbols(p *documentSymbolParams) any {
+	symbols := make([]symbolInformation, 0)
+	d, ok := s.documents[p.TextDocument.URI]
+	if !ok {
+		return symbols
+	}
+	for _, symbol := range d.symbols {
+		if symbol.Declaration != nil {
+			symbols = append(symbols, symbolInformation{symbol.Name, symbolKindVariable, d.location(symbol.Declaration)})
+		}
+	}
+	return symbols
+}
+
+func (s *server) completion() any {
+	items := make([]completionItem, 0)
+	for _, k := range scanner.Keywords() {
+		items = append(items, completionItem{k, completionKeyword})
+	}
+	return items
+}
+
+func decode[T any](params json.RawMessage) (*T, error) {
+	v := new(T)
+	if err := json.Unmarshal(params, v); err != nil {
+		return nil, &responseError{codeInvalidParams, err.Error()}
+	}
+	return v, nil
+}
+
+func (s *server) handle(m *message) (any, error) {
+	switch m.Method {
+	case "initialize":
+		p := new(initializeParams)
+		if len(m.Params) > 0 {
+			var err error
+			if p, err = decode[initializeParams](m.Params); err != nil {
+				return nil, err
+			}
+		}
+		return s.initialize(p), nil
+	case "shutdown":
+		return nil, nil
+	case "exit":
+		s.exit = true
+		return nil, nil
+	case "textDocument/didOpen":
+		p, err := decode[didOpenParams](m.Params)
+		if err != nil {
+			return nil, err
+		}
+		return nil, s.open(p.TextDocument.URI, p.TextDocument.Text)
+	case "textDocument/didChange":
+		p, err := decode[didChangeParams](m.Params)
+		if err != nil {
+			return nil, err
+		}
+		if len(p.ContentChanges) == 0 {
+			return nil, nil
+		}
+		return nil, s.open(p.TextDocument.URI, p.ContentChanges[len(p.ContentChanges)-1].Text)
+	case "textDocument/didClose":
+		p, err := decode[didCloseParams](m.Params)
+		if err != nil {
+			return nil, err
+		}
+		delete(s.documents, p.TextDocument.URI)
+		return nil, s.publishDiagnostics(p.TextDocument.URI, make([]diagnostic, 0))
+	case "textDocument/definition":
+		p, err := decode[textDocumentPositionParams](m.Params)
+		if err != nil {
+			return nil, err
+		}
+		return s.definition(p), nil
+	case "textDocument/references":
+		p, err := decode[referenceParams](m.Params)
+		if err != nil {
+			return nil, err
+		}
+		return s.references(p), nil
+	case "textDocument/hover":
+		p, err := decode[textDocumentPositionParams](m.Params)
+		if err != nil {
+			return nil, err
+		}
+		return s.hover(p), nil
+	case "textDocument/documentSymbol":
+		p, err := decode[documentSymbolParams](m.Params)
+		if err != nil {
+			return nil, err
+		}
+		return s.documentSymbols(p), nil
+	case "textDocument/completion":
+		return s.completion(), nil
+	default:
+		return nil, &responseError{codeMethodNotFound, fmt.Sprintf("method '%s' not found", m.Method)}
+	}
+}
+
+func (s *server) Run() error {
+	for !s.exit {
+		content, err := s.conn.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		var m message
+		if err = json.Unmarshal(content, &m); err != nil {
+			if err = s.conn.Write(&errorResponse{"2.0", json.RawMessage("null"), &responseError{codeParseError, err.Error()}}); err != nil {
+				return err
+			}
+			continue
+		}
+		result, err := s.handle(&m)
+		var responseErr *responseError
+		if err != nil && !errors.As(err, &responseErr) {
+			return err
+		}
+		if m.ID == nil {
+			continue
+		}
+		if responseErr != nil {
+			err = s.conn.Write(&errorResponse{"2.0", m.ID, responseErr})
+		} else {
+			err = s.conn.Write(&response{"2.0", m.ID, result})
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
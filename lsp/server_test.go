@@ -0,0 +1,251 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/lukibw/abc/scanner"
+	"github.com/lukibw/abc/transport"
+)
+
+type incoming struct {
+	ID     *int            `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+	Result json.RawMessage `json:"result"`
+	Error  *responseError  `json:"error"`
+}
+
+type client struct {
+	t    *testing.T
+	conn transport.Conn
+	id   int
+}
+
+// start runs a server connected to the returned client through pipes. The
+// server stops when the test ends and closes the client side.
+func start(t *testing.T) *client {
+	t.Helper()
+	serverReader, clientWriter := io.Pipe()
+	clientReader, serverWriter := io.Pipe()
+	done := make(chan error, 1)
+	go func() {
+		done <- New(serverReader, serverWriter).Run()
+		serverWriter.Close()
+	}()
+	t.Cleanup(func() {
+		clientWriter.Close()
+		if err := <-done; err != nil {
+			t.Errorf("Run() error = %v", err)
+		}
+	})
+	return &client{t, transport.New(clientReader, clientWriter), 0}
+}
+
+func (c *client) read() *incoming {
+	c.t.Helper()
+	content, err := c.conn.Read()
+	if err != nil {
+		c.t.Fatalf("read: %v", err)
+	}
+	var m incoming
+	if err = json.Unmarshal(content, &m); err != nil {
+		c.t.Fatalf("unmarshal %s: %v", content, err)
+	}
+	return &m
+}
+
+func (c *client) notify(method string, params any) {
+	c.t.Helper()
+	if err := c.conn.Write(map[string]any{"jsonrpc": "2.0", "method": method, "params": params}); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+}
+
+// request sends a request and decodes the result of its response into
+// result.
+func (c *client) request(method string, params any, result any) {
+	c.t.Helper()
+	c.id++
+	if err := c.conn.Write(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": method, "params": params}); err != nil {
+		c.t.Fatalf("write: %v", err)
+	}
+	m := c.read()
+	if m.ID == nil || *m.ID != c.id {
+		c.t.Fatalf("got %+v, want response to request %d", m, c.id)
+	}
+	if m.Error != nil {
+		c.t.Fatalf("%s: error %d: %s", method, m.Error.Code, m.Error.Message)
+	}
+	if err := json.Unmarshal(m.Result, result); err != nil {
+		c.t.Fatalf("unmarshal %s: %v", m.Result, err)
+	}
+}
+
+// open opens a document and returns the diagnostics published for it.
+func (c *client) open(uri, text string) []diagnostic {
+	c.t.Helper()
+	c.notify("textDocument/didOpen", map[string]any{"textDocument": map[string]any{"uri": uri, "text": text}})
+	m := c.read()
+	if m.Method != "textDocument/publishDiagnostics" {
+		c.t.Fatalf("got %+v, want diagnostics", m)
+	}
+	var p publishDiagnosticsParams
+	if err := json.Unmarshal(m.Params, &p); err != nil {
+		c.t.Fatalf("unmarshal %s: %v", m.Params, err)
+	}
+	if p.URI != uri {
+		c.t.Fatalf("diagnostics for %s, want %s", p.URI, uri)
+	}
+	return p.Diagnostics
+}
+
+func at(uri string, line, character int) map[string]any {
+	return map[string]any{"textDocument": map[string]any{"uri": uri}, "position": position{line, character}}
+}
+
+func TestInitialize(t *testing.T) {
+	c := start(t)
+	var result struct {
+		Capabilities map[string]any `json:"capabilities"`
+	}
+	c.request("initialize", map[string]any{"capabilities": map[string]any{}}, &result)
+	for _, name := range []string{"hoverProvider", "definitionProvider", "referencesProvider", "documentSymbolProvider"} {
+		if result.Capabilities[name] != true {
+			t.Errorf("capability %s = %v, want true", name, result.Capabilities[name])
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := start(t)
+	diagnostics := c.open("file:///a.abc", "print ;\nvar x = 1;\nx = ;\n")
+	want := []textRange{
+		{position{0, 6}, position{0, 7}},
+		{position{2, 4}, position{2, 5}},
+	}
+	if len(diagnostics) != len(want) {
+		t.Fatalf("got %d diagnostics %+v, want %d", len(diagnostics), diagnostics, len(want))
+	}
+	for i, d := range diagnostics {
+		if d.Range != want[i] || d.Message != "missing expression" {
+			t.Errorf("diagnostic %d = %+v, want 'missing expression' at %+v", i, d, want[i])
+		}
+	}
+	if diagnostics = c.open("file:///a.abc", "print 1;\n"); len(diagnostics) != 0 {
+		t.Errorf("got diagnostics %+v for a valid document", diagnostics)
+	}
+}
+
+func TestHover(t *testing.T) {
+	c := start(t)
+	c.open("file:///a.abc", "var x = 1;\n{\n  var y = x;\n  print y;\n}\n")
+	var h hover
+	c.request("textDocument/hover", at("file:///a.abc", 2, 10), &h)
+	if want := "global variable 'x' declared on line 1"; h.Contents.Value != want {
+		t.Errorf("hover = %q, want %q", h.Contents.Value, want)
+	}
+	if want := (textRange{position{2, 10}, position{2, 11}}); h.Range != want {
+		t.Errorf("hover range = %+v, want %+v", h.Range, want)
+	}
+	c.request("textDocument/hover", at("file:///a.abc", 3, 8), &h)
+	if want := "local variable 'y' declared on line 3"; h.Contents.Value != want {
+		t.Errorf("hover = %q, want %q", h.Contents.Value, want)
+	}
+	var none *hover
+	c.request("textDocument/hover", at("file:///a.abc", 1, 0), &none)
+	if none != nil {
+		t.Errorf("hover = %+v outside of a variable, want null", none)
+	}
+}
+
+func TestDefinition(t *testing.T) {
+	c := start(t)
+	c.open("file:///a.abc", "var count = 1;\nprint count + 1;\n")
+	var l location
+	c.request("textDocument/definition", at("file:///a.abc", 1, 8), &l)
+	want := location{"file:///a.abc", textRange{position{0, 4}, position{0, 9}}}
+	if l != want {
+		t.Errorf("definition = %+v, want %+v", l, want)
+	}
+}
+
+func TestUnknownMethod(t *testing.T) {
+	c := start(t)
+	c.id++
+	if err := c.conn.Write(map[string]any{"jsonrpc": "2.0", "id": c.id, "method": "workspace/unknown"}); err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if m := c.read(); m.Error == nil || m.Error.Code != codeMethodNotFound {
+		t.Errorf("got %+v, want method not found", m)
+	}
+}
+
+func TestTokenRange(t *testing.T) {
+	tests := []struct {
+		token scanner.Token
+		want  textRange
+	}{
+		{scanner.Token{Kind: scanner.TokenIdentifier, Line: 2, Column: 3, Lexeme: "héllo"}, textRange{position{1, 2}, position{1, 7}}},
+		{scanner.Token{Kind: scanner.TokenString, Line: 1, Column: 7, Lexeme: "\"a\nbc\nd€f\""}, textRange{position{0, 6}, position{2, 4}}},
+	}
+	for _, tt := range tests {
+		if got := tokenRange(&tt.token); got != tt.want {
+			t.Errorf("tokenRange(%q) = %+v, want %+v", tt.token.Lexeme, got, tt.want)
+		}
+	}
+}
+
+func TestMultilineStringHover(t *testing.T) {
+	c := start(t)
+	text := strings.Join([]string{"var s = \"a", "b\"; var t = s;", "print t;"}, "\n")
+	c.open("file:///a.abc", text)
+	var h hover
+	c.request("textDocument/hover", at("file:///a.abc", 1, 12), &h)
+	if want := "global variable 's' declared on line 1"; h.Contents.Value != want {
+		t.Errorf("hover = %q, want %q", h.Contents.Value, want)
+	}
+}
+
+func TestPositionEncoding(t *testing.T) {
+	// The emoji takes two UTF-16 code units and one code point.
+	text := "var s = \"😀\"; var x = s;\nprint x;\n"
+	tests := []struct {
+		name      string
+		offered   []string
+		encoding  string
+		character int
+	}{
+		{"default", nil, encodingUTF16, 18},
+		{"utf-16 only", []string{encodingUTF16}, encodingUTF16, 18},
+		{"utf-32 offered", []string{encodingUTF16, encodingUTF32}, encodingUTF32, 17},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := start(t)
+			var result struct {
+				Capabilities struct {
+					PositionEncoding string `json:"positionEncoding"`
+				} `json:"capabilities"`
+			}
+			params := map[string]any{"capabilities": map[string]any{"general": map[string]any{"positionEncodings": tt.offered}}}
+			c.request("initialize", params, &result)
+			if result.Capabilities.PositionEncoding != tt.encoding {
+				t.Fatalf("positionEncoding = %q, want %q", result.Capabilities.PositionEncoding, tt.encoding)
+			}
+			c.open("file:///a.abc", text)
+			var l location
+			c.request("textDocument/definition", at("file:///a.abc", 1, 6), &l)
+			if want := (textRange{position{0, tt.character}, position{0, tt.character + 1}}); l.Range != want {
+				t.Errorf("definition range = %+v, want %+v", l.Range, want)
+			}
+			var h hover
+			c.request("textDocument/hover", at("file:///a.abc", 0, tt.character+4), &h)
+			if want := "global variable 's' declared on line 1"; h.Contents.Value != want {
+				t.Errorf("hover after the emoji = %q, want %q", h.Contents.Value, want)
+			}
+		})
+	}
+}
@@ -1,28 +1,207 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
 	"log"
 	"os"
 
 	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/cover"
+	"github.com/lukibw/abc/dap"
+	"github.com/lukibw/abc/debugger"
+	"github.com/lukibw/abc/golden"
+	"github.com/lukibw/abc/lint"
+	"github.com/lukibw/abc/lsp"
+	"github.com/lukibw/abc/profile"
 	"github.com/lukibw/abc/scanner"
 	"github.com/lukibw/abc/vm"
 )
 
+var commands = map[string]func(args []string) error{
+	"run":   runCommand,
+	"lint":  lintCommand,
+	"lsp":   lspCommand,
+	"debug": debugCommand,
+	"dap":   dapCommand,
+	"test":  testCommand,
+}
+
 func main() {
-	debug, err := os.Create("main.log")
-	if err != nil {
+	args := os.Args[1:]
+	if len(args) == 0 {
+		args = []string{"run", "-trace", "main.log", "main.abc"}
+	}
+	command, ok := commands[args[0]]
+	if !ok {
+		log.Fatalf("unknown command '%s'\n", args[0])
+	}
+	if err := command(args[1:]); err != nil {
 		log.Fatalln(err)
 	}
-	content, err := os.ReadFile("main.abc")
+}
+
+func runCommand(args []string) error {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	trace := flags.String("trace", "", "write an instruction trace to `file`")
+	profiling := flags.Bool("profile", false, "print instruction counts and time per operation and line")
+	pprof := flags.String("pprof", "", "write a pprof profile to `file`")
+	covering := flags.Bool("cover", false, "print line and branch coverage")
+	lcov := flags.String("lcov", "", "write line and branch coverage in LCOV format to `file`")
+	coverHTML := flags.String("coverhtml", "", "write an HTML coverage report to `file`")
+	timeout := flags.Duration("timeout", 0, "stop the script after `duration`")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: abc run [-trace file] [-profile] [-pprof file] [-cover] [-lcov file] [-coverhtml file] [-timeout duration] <file>")
+	}
+	content, err := os.ReadFile(flags.Arg(0))
 	if err != nil {
-		log.Fatalln(err)
+		return err
+	}
+	options := make([]vm.Option, 0)
+	if *trace != "" {
+		f, err := os.Create(*trace)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		options = append(options, vm.WithTrace(log.New(f, "", 0)))
+	}
+	var c cover.Coverage
+	if *covering || *lcov != "" || *coverHTML != "" {
+		c = cover.New(flags.Arg(0), content)
+		options = append(options, vm.WithHook(c.Hook))
+	}
+	// The profiler comes last so that it does not time the other hooks.
+	var p profile.Profiler
+	if *profiling || *pprof != "" {
+		p = profile.New(flags.Arg(0), content)
+		options = append(options, vm.WithHook(p.Hook), vm.WithAfterHook(p.After))
 	}
-	vm, err := vm.New(compiler.New(scanner.New(content)), log.New(debug, "", 0))
+	vm, err := vm.New(compiler.New(scanner.New(content)), options...)
 	if err != nil {
-		log.Fatalln(err)
+		return err
 	}
-	if err = vm.Run(); err != nil {
-		log.Fatalln(err)
+	ctx := context.Background()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
+	}
+	if err = vm.RunContext(ctx); err != nil {
+		return err
+	}
+	if *profiling {
+		if err = p.Report(os.Stderr); err != nil {
+			return err
+		}
+	}
+	if *covering {
+		if err = c.Summary(os.Stderr); err != nil {
+			return err
+		}
+	}
+	if *pprof != "" {
+		if err = writeFile(*pprof, p.WritePprof); err != nil {
+			return err
+		}
+	}
+	if *lcov != "" {
+		if err = writeFile(*lcov, c.WriteLCOV); err != nil {
+			return err
+		}
+	}
+	if *coverHTML != "" {
+		return writeFile(*coverHTML, c.WriteHTML)
+	}
+	return nil
+}
+
+func writeFile(path string, write func(w io.Writer) error) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err = write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func lintCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: abc lint <file>...")
+	}
+	count := 0
+	for _, path := range args {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		warnings, err := lint.Run(content)
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		for _, w := range warnings {
+			fmt.Printf("%s: %s\n", path, w)
+		}
+		count += len(warnings)
+	}
+	if count > 0 {
+		return fmt.Errorf("found %d warnings", count)
+	}
+	return nil
+}
+
+func lspCommand(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: abc lsp")
+	}
+	return lsp.New(os.Stdin, os.Stdout).Run()
+}
+
+func debugCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: abc debug <file>")
+	}
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	d := debugger.New(content, os.Stdin, os.Stdout)
+	vm, err := vm.New(compiler.New(scanner.New(content)), vm.WithHook(d.Hook))
+	if err != nil {
+		return err
+	}
+	if err = vm.Run(); err != nil && !errors.Is(err, debugger.ErrQuit) {
+		return err
+	}
+	return nil
+}
+
+func dapCommand(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: abc dap")
+	}
+	return dap.New(os.Stdin, os.Stdout).Run()
+}
+
+func testCommand(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: abc test <dir>")
+	}
+	passed, err := golden.Run(args[0], os.Stdout)
+	if err != nil {
+		return err
+	}
+	if !passed {
+		return fmt.Errorf("tests failed")
 	}
+	return nil
 }
@@ -0,0 +1,231 @@
+package profile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/vm"
+)
+
+// Profiler counts the executed instructions and times them. Hook has to run
+// before and After after each instruction. The time between them is
+// measured, so hooks registered after Hook, or after hooks registered
+// before After, are timed along with the instruction. Hook should be the
+// last hook of the VM and After the first after hook.
+type Profiler interface {
+	Hook(v vm.VM) error
+	After(v vm.VM) error
+	Report(w io.Writer) error
+	WritePprof(w io.Writer) error
+}
+
+func New(name string, source []byte) Profiler {
+	return &profiler{name, strings.Split(string(source), "\n"), make(map[key]*sample), nil, time.Time{}, time.Now()}
+}
+
+type key struct {
+	line      int
+	operation compiler.Operation
+}
+
+type sample struct {
+	count int64
+	time  time.Duration
+}
+
+func (s *sample) add(o *sample) {
+	s.count += o.count
+	s.time += o.time
+}
+
+type profiler struct {
+	name    string
+	source  []string
+	samples map[key]*sample
+	last    *sample
+	lastAt  time.Time
+	start   time.Time
+}
+
+func (p *profiler) Hook(v vm.VM) error {
+	chunk := v.Chunk()
+	k := key{chunk.Lines[v.Offset()], compiler.Operation(chunk.Code[v.Offset()])}
+	s, ok := p.samples[k]
+	if !ok {
+		s = &sample{}
+		p.samples[k] = s
+	}
+	s.count++
+	p.last = s
+	p.lastAt = time.Now()
+	return nil
+}
+
+func (p *profiler) After(vm.VM) error {
+	if p.last != nil {
+		p.last.time += time.Since(p.lastAt)
+		p.last = nil
+	}
+	return nil
+}
+
+func (p *profiler) sourceLine(line int) string {
+	if line < 1 || line > len(p.source) {
+		return ""
+	}
+	return strings.TrimSpace(p.source[line-1])
+}
+
+func (p *profiler) Report(w io.Writer) error {
+	operations := make(map[compiler.Operation]*sample)
+	lines := make(map[int]*sample)
+	var total int64
+	for k, s := range p.samples {
+		if operations[k.operation] == nil {
+			operations[k.operation] = &sample{}
+		}
+		operations[k.operation].add(s)
+		if lines[k.line] == nil {
+			lines[k.line] = &sample{}
+		}
+		lines[k.line].add(s)
+		total += s.count
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "instructions\tpercent\toperation\t\n")
+	ops := make([]compiler.Operation, 0, len(operations))
+	for o := range operations {
+		ops = append(ops, o)
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		if operations[ops[i]].count != operations[ops[j]].count {
+			return operations[ops[i]].count > operations[ops[j]].count
+		}
+		return ops[i] < ops[j]
+	})
+	for _, o := range ops {
+		fmt.Fprintf(tw, "%d\t%.2f%%\t%s\t\n", operations[o].count, percent(operations[o].count, total), o)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(w)
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(tw, "instructions\ttime\tline\t\n")
+	ls := make([]int, 0, len(lines))
+	for l := range lines {
+		ls = append(ls, l)
+	}
+	sort.Slice(ls, func(i, j int) bool {
+		if lines[ls[i]].time != lines[ls[j]].time {
+			return lines[ls[i]].time > lines[ls[j]].time
+		}
+		return ls[i] < ls[j]
+	})
+	for _, l := range ls {
+		fmt.Fprintf(tw, "%d\t%s\t%d\t %s\n", lines[l].count, lines[l].time, l, p.sourceLine(l))
+	}
+	return tw.Flush()
+}
+
+func percent(count, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return float64(count) * 100 / float64(total)
+}
+
+// WritePprof writes the samples as a gzipped profile.proto message. Every
+// sample is a two frame stack: the executed operation called from its line.
+func (p *profiler) WritePprof(w io.Writer) error {
+	strs := []string{""}
+	index := make(map[string]int64)
+	str := func(s string) int64 {
+		if i, ok := index[s]; ok {
+			return i
+		}
+		strs = append(strs, s)
+		index[s] = int64(len(strs) - 1)
+		return index[s]
+	}
+	var profile, m encoder
+	valueType := func(field int, typ, unit string) {
+		m.Reset()
+		m.int64(1, str(typ))
+		m.int64(2, str(unit))
+		profile.message(field, &m)
+	}
+	valueType(1, "instructions", "count")
+	valueType(1, "time", "nanoseconds")
+	functions := make(map[string]uint64)
+	function := func(name string, line int) uint64 {
+		if id, ok := functions[name]; ok {
+			return id
+		}
+		id := uint64(len(functions) + 1)
+		functions[name] = id
+		m.Reset()
+		m.uint64(1, id)
+		m.int64(2, str(name))
+		m.int64(3, str(name))
+		m.int64(4, str(p.name))
+		m.int64(5, int64(line))
+		profile.message(5, &m)
+		return id
+	}
+	locations := make(map[string]uint64)
+	location := func(name string, line int) uint64 {
+		k := fmt.Sprintf("%s:%d", name, line)
+		if id, ok := locations[k]; ok {
+			return id
+		}
+		f := function(name, line)
+		id := uint64(len(locations) + 1)
+		locations[k] = id
+		var l encoder
+		l.uint64(1, f)
+		l.int64(2, int64(line))
+		m.Reset()
+		m.uint64(1, id)
+		m.message(4, &l)
+		profile.message(4, &m)
+		return id
+	}
+	keys := make([]key, 0, len(p.samples))
+	for k := range p.samples {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].line != keys[j].line {
+			return keys[i].line < keys[j].line
+		}
+		return keys[i].operation < keys[j].operation
+	})
+	for _, k := range keys {
+		s := p.samples[k]
+		caller := location(fmt.Sprintf("%s:%d", p.name, k.line), k.line)
+		leaf := location(k.operation.String(), k.line)
+		var sm encoder
+		sm.packed(1, []uint64{leaf, caller})
+		sm.packed(2, []uint64{uint64(s.count), uint64(s.time.Nanoseconds())})
+		profile.message(2, &sm)
+	}
+	profile.int64(9, p.start.UnixNano())
+	profile.int64(10, time.Since(p.start).Nanoseconds())
+	valueType(11, "instructions", "count")
+	profile.int64(12, 1)
+	for _, s := range strs {
+		profile.string(6, s)
+	}
+	gz := gzip.NewWriter(w)
+	if _, err := gz.Write(profile.Bytes()); err != nil {
+		return err
+	}
+	return gz.Close()
+}
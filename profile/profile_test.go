@@ -0,0 +1,220 @@
+package profile
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+	"github.com/lukibw/abc/vm"
+)
+
+const source = `var a = 1;
+for (var i = 0; i < 3; i++) {
+  a = a + i;
+}
+print a;
+`
+
+func profile(t *testing.T) Profiler {
+	t.Helper()
+	p := New("main.abc", []byte(source))
+	v, err := vm.New(compiler.New(scanner.New([]byte(source))), vm.WithHook(p.Hook), vm.WithAfterHook(p.After), vm.WithoutPrint())
+	if err != nil {
+		t.Fatalf("vm.New() error = %v", err)
+	}
+	if err = v.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	return p
+}
+
+var (
+	operationCounts = map[string]int64{
+		"POP": 14, "GET_LOCAL": 13, "CONSTANT": 9, "ADD": 6, "LOOP": 6,
+		"GET_GLOBAL": 4, "LESS": 4, "JUMP_IF_FALSE": 4, "SET_GLOBAL": 3,
+		"SET_LOCAL": 3, "JUMP": 3, "RETURN": 1, "PRINT": 1, "DEFINE_GLOBAL": 1,
+	}
+	lineCounts = map[int]int64{1: 2, 2: 47, 3: 15, 4: 5, 5: 2, 6: 1}
+)
+
+const total = 72
+
+func TestReport(t *testing.T) {
+	var report strings.Builder
+	if err := profile(t).Report(&report); err != nil {
+		t.Fatalf("Report() error = %v", err)
+	}
+	operations, lines, ok := strings.Cut(report.String(), "\n\n")
+	if !ok {
+		t.Fatalf("Report() = %q, want two tables", report.String())
+	}
+	gotOperations := make(map[string]int64)
+	for _, row := range strings.Split(operations, "\n")[1:] {
+		fields := strings.Fields(row)
+		count, _ := strconv.ParseInt(fields[0], 10, 64)
+		if want := fmt.Sprintf("%.2f%%", float64(count)*100/total); fields[1] != want {
+			t.Errorf("percent of %s = %s, want %s", fields[2], fields[1], want)
+		}
+		gotOperations[fields[2]] = count
+	}
+	if fmt.Sprint(gotOperations) != fmt.Sprint(operationCounts) {
+		t.Errorf("operation counts = %v, want %v", gotOperations, operationCounts)
+	}
+	gotLines := make(map[int]int64)
+	for _, row := range strings.Split(strings.TrimSuffix(lines, "\n"), "\n")[1:] {
+		fields := strings.Fields(row)
+		count, _ := strconv.ParseInt(fields[0], 10, 64)
+		line, _ := strconv.Atoi(fields[2])
+		gotLines[line] = count
+		if line == 3 && !strings.HasSuffix(row, " a = a + i;") {
+			t.Errorf("row of line 3 = %q, want its source", row)
+		}
+	}
+	if fmt.Sprint(gotLines) != fmt.Sprint(lineCounts) {
+		t.Errorf("line counts = %v, want %v", gotLines, lineCounts)
+	}
+}
+
+// decode splits a protobuf message into its fields, keeping varints and the
+// contents of length delimited fields.
+func decode(t *testing.T, b []byte) map[int][]any {
+	t.Helper()
+	fields := make(map[int][]any)
+	varint := func() uint64 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			if len(b) == 0 {
+				t.Fatalf("truncated varint")
+			}
+			c := b[0]
+			b = b[1:]
+			x |= uint64(c&0x7f) << shift
+			if c < 0x80 {
+				return x
+			}
+		}
+	}
+	for len(b) > 0 {
+		tag := varint()
+		switch field := int(tag >> 3); tag & 7 {
+		case wireVarint:
+			fields[field] = append(fields[field], varint())
+		case wireBytes:
+			n := varint()
+			if uint64(len(b)) < n {
+				t.Fatalf("truncated field %d", field)
+			}
+			fields[field] = append(fields[field], b[:n])
+			b = b[n:]
+		default:
+			t.Fatalf("unexpected wire type %d", tag&7)
+		}
+	}
+	return fields
+}
+
+func packed(t *testing.T, b []byte) []uint64 {
+	t.Helper()
+	xs := make([]uint64, 0)
+	for len(b) > 0 {
+		var x uint64
+		for shift := 0; ; shift += 7 {
+			c := b[0]
+			b = b[1:]
+			x |= uint64(c&0x7f) << shift
+			if c < 0x80 {
+				break
+			}
+		}
+		xs = append(xs, x)
+	}
+	return xs
+}
+
+func integer(fields map[int][]any, field int) uint64 {
+	if len(fields[field]) == 0 {
+		return 0
+	}
+	return fields[field][0].(uint64)
+}
+
+func TestWritePprof(t *testing.T) {
+	var buf bytes.Buffer
+	if err := profile(t).WritePprof(&buf); err != nil {
+		t.Fatalf("WritePprof() error = %v", err)
+	}
+	gz, err := gzip.NewReader(&buf)
+	if err != nil {
+		t.Fatalf("gzip.NewReader() error = %v", err)
+	}
+	data, err := io.ReadAll(gz)
+	if err != nil {
+		t.Fatalf("reading gzip error = %v", err)
+	}
+	p := decode(t, data)
+	strs := make([]string, len(p[6]))
+	for i, s := range p[6] {
+		strs[i] = string(s.([]byte))
+	}
+	if len(strs) == 0 || strs[0] != "" {
+		t.Fatalf("string table = %q, want it to start with the empty string", strs)
+	}
+	types := make([]string, 0)
+	for _, m := range p[1] {
+		vt := decode(t, m.([]byte))
+		types = append(types, strs[integer(vt, 1)]+"/"+strs[integer(vt, 2)])
+	}
+	if fmt.Sprint(types) != "[instructions/count time/nanoseconds]" {
+		t.Fatalf("sample types = %v", types)
+	}
+	functions := make(map[uint64]string)
+	for _, m := range p[5] {
+		f := decode(t, m.([]byte))
+		functions[integer(f, 1)] = strs[integer(f, 2)]
+		if strs[integer(f, 4)] != "main.abc" {
+			t.Errorf("function file = %q, want main.abc", strs[integer(f, 4)])
+		}
+	}
+	type frame struct {
+		function string
+		line     uint64
+	}
+	locations := make(map[uint64]frame)
+	for _, m := range p[4] {
+		l := decode(t, m.([]byte))
+		line := decode(t, l[4][0].([]byte))
+		locations[integer(l, 1)] = frame{functions[integer(line, 1)], integer(line, 2)}
+	}
+	gotOperations := make(map[string]int64)
+	gotLines := make(map[int]int64)
+	for _, m := range p[2] {
+		s := decode(t, m.([]byte))
+		stack := packed(t, s[1][0].([]byte))
+		values := packed(t, s[2][0].([]byte))
+		if len(stack) != 2 || len(values) != 2 {
+			t.Fatalf("sample stack = %v, values = %v", stack, values)
+		}
+		leaf, caller := locations[stack[0]], locations[stack[1]]
+		if caller.function != fmt.Sprintf("main.abc:%d", caller.line) || leaf.line != caller.line {
+			t.Errorf("sample frames = %v, %v", leaf, caller)
+		}
+		gotOperations[leaf.function] += int64(values[0])
+		gotLines[int(caller.line)] += int64(values[0])
+	}
+	if fmt.Sprint(gotOperations) != fmt.Sprint(operationCounts) {
+		t.Errorf("operation counts = %v, want %v", gotOperations, operationCounts)
+	}
+	if fmt.Sprint(gotLines) != fmt.Sprint(lineCounts) {
+		t.Errorf("line counts = %v, want %v", gotLines, lineCounts)
+	}
+	period := decode(t, p[11][0].([]byte))
+	if strs[integer(period, 1)] != "instructions" || integer(p, 12) != 1 || integer(p, 9) == 0 {
+		t.Errorf("period type = %q, period = %d, time = %d", strs[integer(period, 1)], integer(p, 12), integer(p, 9))
+	}
+}
@@ -0,0 +1,58 @@
+package profile
+
+import "bytes"
+
+const (
+	wireVarint = 0
+	wireBytes  = 2
+)
+
+type encoder struct {
+	bytes.Buffer
+}
+
+func (e *encoder) varint(x uint64) {
+	for x >= 0x80 {
+		e.WriteByte(byte(x) | 0x80)
+		x >>= 7
+	}
+	e.WriteByte(byte(x))
+}
+
+func (e *encoder) tag(field, wire int) {
+	e.varint(uint64(field)<<3 | uint64(wire))
+}
+
+func (e *encoder) uint64(field int, x uint64) {
+	if x == 0 {
+		return
+	}
+	e.tag(field, wireVarint)
+	e.varint(x)
+}
+
+func (e *encoder) int64(field int, x int64) {
+	e.uint64(field, uint64(x))
+}
+
+func (e *encoder) bytes(field int, b []byte) {
+	e.tag(field, wireBytes)
+	e.varint(uint64(len(b)))
+	e.Write(b)
+}
+
+func (e *encoder) string(field int, s string) {
+	e.bytes(field, []byte(s))
+}
+
+func (e *encoder) message(field int, m *encoder) {
+	e.bytes(field, m.Bytes())
+}
+
+func (e *encoder) packed(field int, xs []uint64) {
+	var p encoder
+	for _, x := range xs {
+		p.varint(x)
+	}
+	e.bytes(field, p.Bytes())
+}
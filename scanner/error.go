@@ -7,11 +7,31 @@ type ErrorKind int
 const (
 	ErrUnexpectedCharacter ErrorKind = iota
 	ErrUnterminatedString
+	ErrInvalidEscape
+	ErrInvalidHexEscape
+	ErrInvalidUnicodeEscape
+	ErrInvalidUTF8
+	ErrMissingDigits
+	ErrInvalidSeparator
+	ErrMissingExponent
+	ErrInvalidNumber
+	ErrInvalidBigInteger
+	ErrEmptyInterpolation
 )
 
 var errorMessages = map[ErrorKind]string{
-	ErrUnexpectedCharacter: "unexpected character",
-	ErrUnterminatedString:  "unterminated string",
+	ErrUnexpectedCharacter:  "unexpected character",
+	ErrUnterminatedString:   "unterminated string",
+	ErrInvalidEscape:        "invalid escape sequence",
+	ErrInvalidHexEscape:     "invalid '\\x' escape, expected two hex digits up to 7f",
+	ErrInvalidUnicodeEscape: "invalid '\\u' escape, expected '{' and up to six hex digits of a code point '}'",
+	ErrInvalidUTF8:          "invalid UTF-8 encoding",
+	ErrMissingDigits:        "missing digits after base prefix",
+	ErrInvalidSeparator:     "'_' must separate two digits",
+	ErrMissingExponent:      "missing digits in exponent",
+	ErrInvalidNumber:        "invalid character in number literal",
+	ErrInvalidBigInteger:    "big integer literal must not have a fraction or an exponent",
+	ErrEmptyInterpolation:   "empty interpolation, expected an expression between '${' and '}'",
 }
 
 func (k ErrorKind) String() string {
@@ -19,10 +39,11 @@ func (k ErrorKind) String() string {
 }
 
 type Error struct {
-	Kind ErrorKind
-	Line int
+	Kind   ErrorKind
+	Line   int
+	Column int
 }
 
 func (e *Error) Error() string {
-	return fmt.Sprintf("[line %d] compilation error: %s", e.Line, e.Kind)
+	return fmt.Sprintf("[line %d, column %d] compilation error: %s", e.Line, e.Column, e.Kind)
 }
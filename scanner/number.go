@@ -0,0 +1,61 @@
+package scanner
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// IsInteger reports whether a number literal denotes an integer, that is
+// whether it has a base prefix or neither a fraction nor an exponent.
+func IsInteger(lexeme string) bool {
+	if len(lexeme) > 1 && lexeme[0] == '0' && strings.ContainsRune("xXbBoO", rune(lexeme[1])) {
+		return true
+	}
+	return !strings.ContainsAny(lexeme, ".eE")
+}
+
+// IsBigInteger reports whether a number literal has the 'n' suffix of big
+// integers.
+func IsBigInteger(lexeme string) bool {
+	return strings.HasSuffix(lexeme, "n")
+}
+
+// base splits the base prefix off an integer literal without separators.
+func base(lexeme string) (string, int) {
+	if len(lexeme) > 2 && lexeme[0] == '0' {
+		switch lexeme[1] {
+		case 'x', 'X':
+			return lexeme[2:], 16
+		case 'b', 'B':
+			return lexeme[2:], 2
+		case 'o', 'O':
+			return lexeme[2:], 8
+		}
+	}
+	return lexeme, 10
+}
+
+// ParseBigInteger returns the value of a big integer literal, which must come
+// from a TokenNumber.
+func ParseBigInteger(lexeme string) *big.Int {
+	digits, b := base(strings.ReplaceAll(strings.TrimSuffix(lexeme, "n"), "_", ""))
+	n, ok := new(big.Int).SetString(digits, b)
+	if !ok {
+		panic("scanner: cannot parse big integer from token lexeme")
+	}
+	return n
+}
+
+// ParseInteger returns the value of an integer literal. The lexeme must come
+// from a TokenNumber, the only error reported is a value out of range.
+func ParseInteger(lexeme string) (int64, error) {
+	digits, b := base(strings.ReplaceAll(lexeme, "_", ""))
+	return strconv.ParseInt(digits, b, 64)
+}
+
+// ParseFloat returns the value of a number literal with a fraction or an
+// exponent, the only error reported is a value out of range.
+func ParseFloat(lexeme string) (float64, error) {
+	return strconv.ParseFloat(strings.ReplaceAll(lexeme, "_", ""), 64)
+}
@@ -1,26 +1,45 @@
 package scanner
 
+import (
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
 type Scanner interface {
 	Token() (*Token, error)
 }
 
 func New(source []byte) Scanner {
-	return &scanner{source, 0, 0, 1}
+	return &scanner{source, 0, 0, 1, 0, 1, 1, nil, false}
+}
+
+// NewWithComments returns a scanner which also produces a TokenComment for
+// every comment, for tools that read them.
+func NewWithComments(source []byte) Scanner {
+	return &scanner{source, 0, 0, 1, 0, 1, 1, nil, true}
 }
 
 type scanner struct {
-	source  []byte
-	start   int
-	current int
-	line    int
+	source      []byte
+	start       int
+	current     int
+	line        int
+	lineStart   int
+	tokenLine   int
+	tokenColumn int
+	// interpolations holds, for every interpolated expression being
+	// scanned, the number of braces opened inside it and not yet closed.
+	interpolations []int
+	comments       bool
 }
 
 func (s *scanner) newToken(k TokenKind) *Token {
-	return &Token{k, s.line, string(s.source[s.start:s.current])}
+	return &Token{k, s.tokenLine, s.tokenColumn, string(s.source[s.start:s.current])}
 }
 
 func (s *scanner) newError(k ErrorKind) error {
-	return &Error{k, s.line}
+	return &Error{k, s.tokenLine, s.tokenColumn}
 }
 
 func (s *scanner) isAtEnd() bool {
@@ -41,6 +60,36 @@ func (s *scanner) peekNext() byte {
 	return s.source[s.current+1]
 }
 
+// rune decodes the character at the current position, which may span
+// several bytes. Invalid UTF-8 is reported at its exact position.
+func (s *scanner) rune() (rune, int, error) {
+	r, size := utf8.DecodeRune(s.source[s.current:])
+	if r == utf8.RuneError && size == 1 {
+		return 0, 0, s.errorAt(ErrInvalidUTF8, s.current)
+	}
+	return r, size, nil
+}
+
+// advanceRune consumes a character, skipping over all bytes of multi-byte
+// characters.
+func (s *scanner) advanceRune() (rune, error) {
+	if s.peek() < utf8.RuneSelf {
+		return rune(s.advance()), nil
+	}
+	r, size, err := s.rune()
+	if err != nil {
+		return 0, err
+	}
+	s.current += size
+	return r, nil
+}
+
+// column returns the 1-based position of the byte offset in its line,
+// counted in characters.
+func (s *scanner) column(offset int) int {
+	return utf8.RuneCount(s.source[s.lineStart:offset]) + 1
+}
+
 func (s *scanner) advance() byte {
 	s.current++
 	return s.source[s.current-1]
@@ -54,34 +103,117 @@ func (s *scanner) match(b byte) bool {
 	return true
 }
 
-func (s *scanner) skipWhitespace() {
+func (s *scanner) newLine() {
+	s.line++
+	s.lineStart = s.current
+}
+
+func (s *scanner) skipWhitespace() error {
 	for {
 		switch s.peek() {
 		case ' ', '\t', '\r':
 			s.advance()
 		case '\n':
 			s.advance()
-			s.line++
+			s.newLine()
 		case '/':
-			if s.peekNext() == '/' {
+			if s.peekNext() == '/' && !s.comments {
 				for s.peek() != '\n' && !s.isAtEnd() {
-					s.advance()
+					if _, err := s.advanceRune(); err != nil {
+						return err
+					}
 				}
 			} else {
-				return
+				return nil
 			}
 		default:
-			return
+			return nil
 		}
 	}
 }
 
+func (s *scanner) errorAt(k ErrorKind, offset int) error {
+	return &Error{k, s.line, s.column(offset)}
+}
+
+func isHexDigit(b byte) bool {
+	return isDigit(b) || (b >= 'a' && b <= 'f') || (b >= 'A' && b <= 'F')
+}
+
+func hexValue(b byte) byte {
+	switch {
+	case b >= 'a':
+		return b - 'a' + 10
+	case b >= 'A':
+		return b - 'A' + 10
+	default:
+		return b - '0'
+	}
+}
+
+// escape validates the escape sequence following a backslash, errors are
+// reported at the position of the backslash.
+func (s *scanner) escape() error {
+	start := s.current - 1
+	if s.isAtEnd() {
+		return nil
+	}
+	switch s.peek() {
+	case '"', '\\', '$', 'n', 't', 'r', '0':
+		s.advance()
+	case 'x':
+		s.advance()
+		if !isHexDigit(s.peek()) || !isHexDigit(s.peekNext()) {
+			return s.errorAt(ErrInvalidHexEscape, start)
+		}
+		if hexValue(s.advance())<<4|hexValue(s.advance()) > 0x7f {
+			return s.errorAt(ErrInvalidHexEscape, start)
+		}
+	case 'u':
+		s.advance()
+		if !s.match('{') {
+			return s.errorAt(ErrInvalidUnicodeEscape, start)
+		}
+		var r rune
+		digits := 0
+		for isHexDigit(s.peek()) {
+			r = r<<4 | rune(hexValue(s.advance()))
+			digits++
+			if digits > 6 {
+				return s.errorAt(ErrInvalidUnicodeEscape, start)
+			}
+		}
+		if digits == 0 || !s.match('}') || !utf8.ValidRune(r) {
+			return s.errorAt(ErrInvalidUnicodeEscape, start)
+		}
+	default:
+		return s.errorAt(ErrInvalidEscape, start)
+	}
+	return nil
+}
+
 func (s *scanner) string() (*Token, error) {
 	for s.peek() != '"' && !s.isAtEnd() {
-		if s.peek() == '\n' {
-			s.line++
+		r, err := s.advanceRune()
+		if err != nil {
+			return nil, err
+		}
+		switch r {
+		case '\n':
+			s.newLine()
+		case '\\':
+			if err := s.escape(); err != nil {
+				return nil, err
+			}
+		case '$':
+			if s.match('{') {
+				if s.emptyInterpolation() {
+					return nil, s.errorAt(ErrEmptyInterpolation, s.current-2)
+				}
+				s.interpolations = append(s.interpolations, 0)
+				return s.newToken(TokenInterpolation), nil
+			}
 		}
-		s.advance()
 	}
 	if s.isAtEnd() {
 		return nil, s.newError(ErrUnterminatedString)
@@ -90,49 +222,165 @@ func (s *scanner) string() (*Token, error) {
 	return s.newToken(TokenString), nil
 }
 
+// emptyInterpolation reports whether only blanks separate the "${" just
+// consumed from the closing '}'.
+func (s *scanner) emptyInterpolation() bool {
+	i := s.current
+	for i < len(s.source) && (s.source[i] == ' ' || s.source[i] == '\t') {
+		i++
+	}
+	return i < len(s.source) && s.source[i] == '}'
+}
+
 func isDigit(b byte) bool {
 	return b >= '0' && b <= '9'
 }
 
+func isBinaryDigit(b byte) bool {
+	return b == '0' || b == '1'
+}
+
+func isOctalDigit(b byte) bool {
+	return b >= '0' && b <= '7'
+}
+
+// digits consumes a run of digits in which single underscores may separate
+// two digits, or follow a base prefix or a digit consumed before when
+// separated is set. It reports whether the run contained any digit.
+func (s *scanner) digits(valid func(byte) bool, separated bool) (bool, error) {
+	found := false
+	for {
+		switch {
+		case valid(s.peek()):
+			s.advance()
+			found = true
+		case s.peek() == '_':
+			if !(found || separated) || !valid(s.peekNext()) {
+				return found, s.errorAt(ErrInvalidSeparator, s.current)
+			}
+			s.advance()
+		default:
+			return found, nil
+		}
+	}
+}
+
 func (s *scanner) number() (*Token, error) {
-	for isDigit(s.peek()) {
-		s.advance()
+	integer := true
+	var valid func(byte) bool
+	if s.source[s.start] == '0' {
+		switch s.peek() {
+		case 'x', 'X':
+			valid = isHexDigit
+		case 'b', 'B':
+			valid = isBinaryDigit
+		case 'o', 'O':
+			valid = isOctalDigit
+		}
 	}
-	if s.peek() == '.' && isDigit(s.peekNext()) {
+	if valid != nil {
 		s.advance()
-		for isDigit(s.peek()) {
+		ok, err := s.digits(valid, true)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			return nil, s.errorAt(ErrMissingDigits, s.current)
+		}
+	} else {
+		if _, err := s.digits(isDigit, true); err != nil {
+			return nil, err
+		}
+		if s.peek() == '.' && isDigit(s.peekNext()) {
+			integer = false
 			s.advance()
+			if _, err := s.digits(isDigit, false); err != nil {
+				return nil, err
+			}
+		}
+		if s.peek() == 'e' || s.peek() == 'E' {
+			integer = false
+			s.advance()
+			if s.peek() == '+' || s.peek() == '-' {
+				s.advance()
+			}
+			ok, err := s.digits(isDigit, false)
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				return nil, s.errorAt(ErrMissingExponent, s.current)
+			}
+		}
+	}
+	if s.peek() == 'n' {
+		if !integer {
+			return nil, s.errorAt(ErrInvalidBigInteger, s.current)
+		}
+		s.advance()
+	}
+	if !s.isAtEnd() {
+		if r, _, err := s.rune(); err == nil && isIdentifierContinue(r) {
+			return nil, s.errorAt(ErrInvalidNumber, s.current)
 		}
 	}
 	return s.newToken(TokenNumber), nil
 }
 
 var keywords = map[string]TokenKind{
-	"and":    TokenAnd,
-	"class":  TokenClass,
-	"else":   TokenElse,
-	"if":     TokenIf,
-	"nil":    TokenNil,
-	"or":     TokenOr,
-	"print":  TokenPrint,
-	"return": TokenReturn,
-	"super":  TokenSuper,
-	"var":    TokenVar,
-	"while":  TokenWhile,
-	"false":  TokenFalse,
-	"for":    TokenFor,
-	"fun":    TokenFun,
-	"this":   TokenThis,
-	"true":   TokenTrue,
-}
-
-func isAlpha(b byte) bool {
-	return (b >= 'a' && b <= 'z') || (b >= 'A' && b <= 'Z') || b == '_'
+	"and":      TokenAnd,
+	"break":    TokenBreak,
+	"continue": TokenContinue,
+	"case":     TokenCase,
+	"default":  TokenDefault,
+	"switch":   TokenSwitch,
+	"match":    TokenMatch,
+	"class":    TokenClass,
+	"else":     TokenElse,
+	"if":       TokenIf,
+	"nil":      TokenNil,
+	"or":       TokenOr,
+	"print":    TokenPrint,
+	"return":   TokenReturn,
+	"super":    TokenSuper,
+	"var":      TokenVar,
+	"while":    TokenWhile,
+	"false":    TokenFalse,
+	"for":      TokenFor,
+	"fun":      TokenFun,
+	"this":     TokenThis,
+	"true":     TokenTrue,
+}
+
+func Keywords() []string {
+	names := make([]string, 0, len(keywords))
+	for name := range keywords {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isIdentifierStart and isIdentifierContinue follow the default identifier
+// syntax of UAX #31, extended with '_' as a start character.
+func isIdentifierStart(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.In(r, unicode.Nl, unicode.Other_ID_Start)
+}
+
+func isIdentifierContinue(r rune) bool {
+	return isIdentifierStart(r) || unicode.In(r, unicode.Mn, unicode.Mc, unicode.Nd, unicode.Pc, unicode.Other_ID_Continue)
 }
 
 func (s *scanner) identifier() (*Token, error) {
-	for isAlpha(s.peek()) || isDigit(s.peek()) {
-		s.advance()
+	for !s.isAtEnd() {
+		r, size, err := s.rune()
+		if err != nil {
+			return nil, err
+		}
+		if !isIdentifierContinue(r) {
+			break
+		}
+		s.current += size
 	}
 	if keyword, ok := keywords[string(s.source[s.start:s.current])]; ok {
 		return s.newToken(keyword), nil
@@ -141,16 +389,23 @@ func (s *scanner) identifier() (*Token, error) {
 }
 
 func (s *scanner) Token() (*Token, error) {
-	s.skipWhitespace()
+	if err := s.skipWhitespace(); err != nil {
+		return nil, err
+	}
 	s.start = s.current
+	s.tokenLine = s.line
+	s.tokenColumn = s.column(s.start)
 	if s.isAtEnd() {
 		return s.newToken(TokenEof), nil
 	}
-	r := s.advance()
-	if isDigit(r) {
+	r, err := s.advanceRune()
+	if err != nil {
+		return nil, err
+	}
+	if r < utf8.RuneSelf && isDigit(byte(r)) {
 		return s.number()
 	}
-	if isAlpha(r) {
+	if isIdentifierStart(r) {
 		return s.identifier()
 	}
 	switch r {
@@ -159,9 +414,23 @@ func (s *scanner) Token() (*Token, error) {
 	case ')':
 		return s.newToken(TokenRightParen), nil
 	case '{':
+		if n := len(s.interpolations); n > 0 {
+			s.interpolations[n-1]++
+		}
 		return s.newToken(TokenLeftBrace), nil
 	case '}':
+		if n := len(s.interpolations); n > 0 {
+			if s.interpolations[n-1] == 0 {
+				s.interpolations = s.interpolations[:n-1]
+				return s.string()
+			}
+			s.interpolations[n-1]--
+		}
 		return s.newToken(TokenRightBrace), nil
+	case '[':
+		return s.newToken(TokenLeftBracket), nil
+	case ']':
+		return s.newToken(TokenRightBracket), nil
 	case ';':
 		return s.newToken(TokenSemicolon), nil
 	case ',':
@@ -169,13 +438,62 @@ func (s *scanner) Token() (*Token, error) {
 	case '.':
 		return s.newToken(TokenDot), nil
 	case '-':
+		if s.match('-') {
+			return s.newToken(TokenMinusMinus), nil
+		}
+		if s.match('=') {
+			return s.newToken(TokenMinusEqual), nil
+		}
 		return s.newToken(TokenMinus), nil
 	case '+':
+		if s.match('+') {
+			return s.newToken(TokenPlusPlus), nil
+		}
+		if s.match('=') {
+			return s.newToken(TokenPlusEqual), nil
+		}
 		return s.newToken(TokenPlus), nil
 	case '/':
+		if s.peek() == '/' {
+			for s.peek() != '\n' && !s.isAtEnd() {
+				if _, err := s.advanceRune(); err != nil {
+					return nil, err
+				}
+			}
+			return s.newToken(TokenComment), nil
+		}
+		if s.match('=') {
+			return s.newToken(TokenSlashEqual), nil
+		}
 		return s.newToken(TokenSlash), nil
 	case '*':
+		if s.match('*') {
+			return s.newToken(TokenStarStar), nil
+		}
+		if s.match('=') {
+			return s.newToken(TokenStarEqual), nil
+		}
 		return s.newToken(TokenStar), nil
+	case '%':
+		return s.newToken(TokenPercent), nil
+	case '?':
+		if s.match('?') {
+			return s.newToken(TokenQuestionQuestion), nil
+		}
+		return s.newToken(TokenQuestion), nil
+	case ':':
+		return s.newToken(TokenColon), nil
+	case '~':
+		if s.match('/') {
+			return s.newToken(TokenTildeSlash), nil
+		}
+		return s.newToken(TokenTilde), nil
+	case '&':
+		return s.newToken(TokenAmpersand), nil
+	case '|':
+		return s.newToken(TokenPipe), nil
+	case '^':
+		return s.newToken(TokenCaret), nil
 	case '!':
 		if s.match('=') {
 			return s.newToken(TokenBangEqual), nil
@@ -185,16 +503,24 @@ func (s *scanner) Token() (*Token, error) {
 	case '=':
 		if s.match('=') {
 			return s.newToken(TokenEqualEqual), nil
+		} else if s.match('>') {
+			return s.newToken(TokenEqualGreater), nil
 		} else {
 			return s.newToken(TokenEqual), nil
 		}
 	case '<':
+		if s.match('<') {
+			return s.newToken(TokenLessLess), nil
+		}
 		if s.match('=') {
 			return s.newToken(TokenLessEqual), nil
 		} else {
 			return s.newToken(TokenLess), nil
 		}
 	case '>':
+		if s.match('>') {
+			return s.newToken(TokenGreaterGreater), nil
+		}
 		if s.match('=') {
 			return s.newToken(TokenGreaterEqual), nil
 		} else {
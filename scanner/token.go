@@ -9,6 +9,8 @@ const (
 	TokenRightParen
 	TokenLeftBrace
 	TokenRightBrace
+	TokenLeftBracket
+	TokenRightBracket
 	TokenComma
 	TokenDot
 	TokenMinus
@@ -16,23 +18,48 @@ const (
 	TokenSemicolon
 	TokenSlash
 	TokenStar
+	TokenStarStar
+	TokenPercent
+	TokenTildeSlash
 	TokenBang
 	TokenBangEqual
 	TokenEqual
 	TokenEqualEqual
+	TokenEqualGreater
 	TokenGreater
 	TokenGreaterEqual
 	TokenLess
 	TokenLessEqual
+	TokenLessLess
+	TokenGreaterGreater
+	TokenAmpersand
+	TokenPipe
+	TokenCaret
+	TokenTilde
+	TokenPlusEqual
+	TokenMinusEqual
+	TokenStarEqual
+	TokenSlashEqual
+	TokenPlusPlus
+	TokenMinusMinus
+	TokenQuestion
+	TokenQuestionQuestion
+	TokenColon
 	TokenIdentifier
 	TokenString
+	TokenInterpolation
 	TokenNumber
 	TokenAnd
+	TokenBreak
+	TokenContinue
+	TokenCase
 	TokenClass
+	TokenDefault
 	TokenElse
 	TokenFalse
 	TokenFor
 	TokenFun
+	TokenMatch
 	TokenIf
 	TokenNil
 	TokenOr
@@ -42,50 +69,81 @@ const (
 	TokenThis
 	TokenTrue
 	TokenVar
+	TokenSwitch
 	TokenWhile
+	TokenComment
 	TokenEof
 )
 
 var tokenKinds = map[TokenKind]string{
-	TokenLeftParen:    "(",
-	TokenRightParen:   ")",
-	TokenLeftBrace:    "[",
-	TokenRightBrace:   "]",
-	TokenComma:        ",",
-	TokenDot:          ".",
-	TokenMinus:        "-",
-	TokenPlus:         "+",
-	TokenSemicolon:    ";",
-	TokenSlash:        "/",
-	TokenStar:         "*",
-	TokenBang:         "!",
-	TokenBangEqual:    "!=",
-	TokenEqual:        "=",
-	TokenEqualEqual:   "==",
-	TokenGreater:      ">",
-	TokenGreaterEqual: ">=",
-	TokenLess:         "<",
-	TokenLessEqual:    "<=",
-	TokenIdentifier:   "IDENTIFIER",
-	TokenString:       "STRING",
-	TokenNumber:       "NUMBER",
-	TokenAnd:          "and",
-	TokenClass:        "class",
-	TokenElse:         "else",
-	TokenFalse:        "false",
-	TokenFor:          "for",
-	TokenFun:          "fun",
-	TokenIf:           "if",
-	TokenNil:          "nil",
-	TokenOr:           "or",
-	TokenPrint:        "print",
-	TokenReturn:       "return",
-	TokenSuper:        "super",
-	TokenThis:         "this",
-	TokenTrue:         "true",
-	TokenVar:          "var",
-	TokenWhile:        "while",
-	TokenEof:          "EOF",
+	TokenLeftParen:        "(",
+	TokenRightParen:       ")",
+	TokenLeftBrace:        "{",
+	TokenRightBrace:       "}",
+	TokenLeftBracket:      "[",
+	TokenRightBracket:     "]",
+	TokenComma:            ",",
+	TokenDot:              ".",
+	TokenMinus:            "-",
+	TokenPlus:             "+",
+	TokenSemicolon:        ";",
+	TokenSlash:            "/",
+	TokenStar:             "*",
+	TokenStarStar:         "**",
+	TokenPercent:          "%",
+	TokenTildeSlash:       "~/",
+	TokenBang:             "!",
+	TokenBangEqual:        "!=",
+	TokenEqual:            "=",
+	TokenEqualEqual:       "==",
+	TokenEqualGreater:     "=>",
+	TokenGreater:          ">",
+	TokenGreaterEqual:     ">=",
+	TokenLess:             "<",
+	TokenLessEqual:        "<=",
+	TokenLessLess:         "<<",
+	TokenGreaterGreater:   ">>",
+	TokenAmpersand:        "&",
+	TokenPipe:             "|",
+	TokenCaret:            "^",
+	TokenTilde:            "~",
+	TokenPlusEqual:        "+=",
+	TokenMinusEqual:       "-=",
+	TokenStarEqual:        "*=",
+	TokenSlashEqual:       "/=",
+	TokenPlusPlus:         "++",
+	TokenMinusMinus:       "--",
+	TokenQuestion:         "?",
+	TokenQuestionQuestion: "??",
+	TokenColon:            ":",
+	TokenIdentifier:       "IDENTIFIER",
+	TokenString:           "STRING",
+	TokenInterpolation:    "INTERPOLATION",
+	TokenNumber:           "NUMBER",
+	TokenBreak:            "break",
+	TokenContinue:         "continue",
+	TokenAnd:              "and",
+	TokenClass:            "class",
+	TokenElse:             "else",
+	TokenFalse:            "false",
+	TokenFor:              "for",
+	TokenFun:              "fun",
+	TokenIf:               "if",
+	TokenNil:              "nil",
+	TokenOr:               "or",
+	TokenPrint:            "print",
+	TokenReturn:           "return",
+	TokenSuper:            "super",
+	TokenThis:             "this",
+	TokenTrue:             "true",
+	TokenVar:              "var",
+	TokenSwitch:           "switch",
+	TokenMatch:            "match",
+	TokenComment:          "comment",
+	TokenWhile:            "while",
+	TokenCase:             "case",
+	TokenDefault:          "default",
+	TokenEof:              "EOF",
 }
 
 func (k TokenKind) String() string {
@@ -95,11 +153,12 @@ func (k TokenKind) String() string {
 type Token struct {
 	Kind   TokenKind
 	Line   int
+	Column int
 	Lexeme string
 }
 
 func (t *Token) String() string {
-	if t.Kind == TokenIdentifier || t.Kind == TokenNumber || t.Kind == TokenString {
+	if t.Kind == TokenIdentifier || t.Kind == TokenNumber || t.Kind == TokenString || t.Kind == TokenInterpolation {
 		return fmt.Sprintf("'%s' (%s)", t.Lexeme, t.Kind)
 	}
 	return fmt.Sprintf("'%s'", t.Kind)
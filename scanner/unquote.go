@@ -0,0 +1,48 @@
+package scanner
+
+import "strings"
+
+// Unquote returns the value of a string literal, removing its delimiters and
+// decoding escape sequences. The lexeme must come from a TokenString or
+// a TokenInterpolation, whose escapes the scanner has already validated.
+// Parts of an interpolated string start with '"' or '}' and end with '"' or
+// "${".
+func Unquote(lexeme string) string {
+	s := strings.TrimSuffix(lexeme[1:], "${")
+	if len(s) == len(lexeme)-1 {
+		s = s[:len(s)-1]
+	}
+	if strings.IndexByte(s, '\\') < 0 {
+		return s
+	}
+	var sb strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+		i++
+		switch s[i] {
+		case 'n':
+			sb.WriteByte('\n')
+		case 't':
+			sb.WriteByte('\t')
+		case 'r':
+			sb.WriteByte('\r')
+		case '0':
+			sb.WriteByte(0)
+		case 'x':
+			sb.WriteByte(hexValue(s[i+1])<<4 | hexValue(s[i+2]))
+			i += 2
+		case 'u':
+			var r rune
+			for i += 2; s[i] != '}'; i++ {
+				r = r<<4 | rune(hexValue(s[i]))
+			}
+			sb.WriteString(string(r))
+		default:
+			sb.WriteByte(s[i])
+		}
+	}
+	return sb.String()
+}
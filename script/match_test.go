@@ -0,0 +1,123 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lukibw/abc/vm"
+)
+
+// classify runs a match over the global subject and returns the result.
+func classify(t *testing.T, arms string, subject any) (any, error) {
+	t.Helper()
+	v := newVM(t, "var result = match (subject) {"+arms+"};")
+	if err := v.Set("subject", subject); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := v.Run(context.Background()); err != nil {
+		return nil, err
+	}
+	return v.Get("result")
+}
+
+func TestMatchPatterns(t *testing.T) {
+	const shapes = `
+  [] => "empty",
+  [x] => "one ${x}",
+  [[a, b], [c]] => "nested ${a}${b}${c}",
+  [x, _] => "two ${x}",
+  {kind: "circle", r} => "circle ${r}",
+  {kind: "rect", size: [w, h]} => "rect ${w}x${h}",
+  {kind, tags: [first, _]} => "${kind} tagged ${first}",
+  {kind} => "other ${kind}",
+  _ => "unknown",
+`
+	tests := []struct {
+		name    string
+		subject any
+		want    string
+	}{
+		{"empty list", []any{}, "empty"},
+		{"single element", []int{1}, "one 1"},
+		{"nested lists", []any{[]int{1, 2}, []int{3}}, "nested 123"},
+		{"nested length mismatch", []any{[]int{1, 2, 3}, []int{3}}, "two [1, 2, 3]"},
+		{"inner length mismatch", []any{[]int{1, 2}, []int{3, 4}}, "two [1, 2]"},
+		{"outer length mismatch", []int{1, 2, 3}, "unknown"},
+		{"record", map[string]any{"kind": "circle", "r": 2}, "circle 2"},
+		{"missing field", map[string]any{"kind": "circle"}, "other circle"},
+		{"nested record list", map[string]any{"kind": "rect", "size": []int{3, 4}}, "rect 3x4"},
+		{"nested record length mismatch", map[string]any{"kind": "rect", "size": []int{3}}, "other rect"},
+		{"field holding list", map[string]any{"kind": "tri", "tags": []string{"a", "b"}}, "tri tagged a"},
+		{"field not a list", map[string]any{"kind": "tri", "tags": "a"}, "other tri"},
+		{"list is not a record", []int{1, 2, 3, 4}, "unknown"},
+		{"record is not a list", map[string]any{"size": 1}, "unknown"},
+		{"scalar", 5, "unknown"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := classify(t, shapes, tt.subject)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("result = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchGuards(t *testing.T) {
+	const arms = `
+  [x, y] if x > y => "descending ${x} ${y}",
+  [x, y] if x == y => "equal ${x}",
+  {lo, hi} if lo <= hi and hi - lo < 10 => "narrow ${lo}..${hi}",
+  {lo, hi: h} if lo <= h => "wide ${lo}..${h}",
+  [x, y] => "ascending ${x} ${y}",
+  v => "fallback",
+`
+	tests := []struct {
+		name    string
+		subject any
+		want    string
+	}{
+		{"first guard", []int{2, 1}, "descending 2 1"},
+		{"second guard", []int{3, 3}, "equal 3"},
+		{"guards fail", []int{1, 2}, "ascending 1 2"},
+		{"record guard", map[string]int{"lo": 1, "hi": 5}, "narrow 1..5"},
+		{"renamed binding guard", map[string]int{"lo": 1, "hi": 50}, "wide 1..50"},
+		{"record guards fail", map[string]int{"lo": 9, "hi": 5}, "fallback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := classify(t, arms, tt.subject)
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if got != tt.want {
+				t.Fatalf("result = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchNoArm(t *testing.T) {
+	tests := []struct {
+		name    string
+		arms    string
+		subject any
+	}{
+		{"length mismatch", "[x, y] => x", []int{1}},
+		{"missing field", "{name} => name", map[string]int{"id": 1}},
+		{"guard fails", "[x] if x > 1 => x", []int{1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := classify(t, tt.arms, tt.subject)
+			var e *vm.Error
+			if !errors.As(err, &e) || e.Kind != vm.ErrNoMatch {
+				t.Fatalf("Run() error = %v, want %v", err, vm.ErrNoMatch)
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"reflect"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+	"github.com/lukibw/abc/vm"
+)
+
+var (
+	ErrUndefinedGlobal = errors.New("script: undefined global variable")
+	ErrNotCallable     = errors.New("script: global is not callable")
+	ErrArity           = errors.New("script: wrong number of arguments")
+)
+
+// Func is a Go function scripts can call once registered. Its arguments and
+// result are converted like the values of Get and Set.
+type Func func(args ...any) (any, error)
+
+type Program interface {
+	VM(options ...vm.Option) (VM, error)
+}
+
+type VM interface {
+	Set(name string, value any) error
+	Get(name string) (any, error)
+	Decode(name string, target any) error
+	Register(name string, fn Func) error
+	Call(name string, args ...any) (any, error)
+	Run(ctx context.Context) error
+}
+
+// Compile compiles the source once, the returned program can then create
+// any number of independent VMs running it. The VMs share the compiled
+// chunk, which they only read, so they may be created and run concurrently.
+func Compile(source string) (Program, error) {
+	c := compiler.New(scanner.New([]byte(source)))
+	if _, err := c.Run(); err != nil {
+		return nil, err
+	}
+	return &program{c}, nil
+}
+
+type program struct {
+	compiler compiler.Compiler
+}
+
+func (p *program) VM(options ...vm.Option) (VM, error) {
+	v, err := vm.New(p.compiler, options...)
+	if err != nil {
+		return nil, err
+	}
+	return &instance{v}, nil
+}
+
+type instance struct {
+	vm vm.VM
+}
+
+func (i *instance) Set(name string, value any) error {
+	v, err := ToValue(value)
+	if err != nil {
+		return err
+	}
+	return i.vm.SetGlobal(name, v)
+}
+
+func (i *instance) Get(name string) (any, error) {
+	v, ok := i.vm.Global(name)
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrUndefinedGlobal, name)
+	}
+	return natural(v, name)
+}
+
+func (i *instance) Decode(name string, target any) error {
+	v, ok := i.vm.Global(name)
+	if !ok {
+		return fmt.Errorf("%w '%s'", ErrUndefinedGlobal, name)
+	}
+	return FromValue(v, target)
+}
+
+// Register defines the global name as a native calling fn, replacing any
+// global of that name.
+func (i *instance) Register(name string, fn Func) error {
+	native := &compiler.Native{
+		Name:    name,
+		MinArgs: 0,
+		MaxArgs: math.MaxUint8,
+		Call: func(args []compiler.Value) (compiler.Value, error) {
+			values := make([]any, len(args))
+			for j, arg := range args {
+				value, err := natural(arg, element(name, j))
+				if err != nil {
+					return compiler.Value{}, err
+				}
+				values[j] = value
+			}
+			result, err := fn(values...)
+			if err != nil {
+				return compiler.Value{}, err
+			}
+			return ToValue(result)
+		},
+	}
+	return i.vm.SetGlobal(name, compiler.NewNative(native))
+}
+
+// Call calls the callable global name, which is a registered function or a
+// native, with the arguments converted by ToValue. It must not be used while
+// Run is running.
+func (i *instance) Call(name string, args ...any) (any, error) {
+	callee, ok := i.vm.Global(name)
+	if !ok {
+		native, ok := compiler.LookupNative(name)
+		if !ok {
+			return nil, fmt.Errorf("%w '%s'", ErrUndefinedGlobal, name)
+		}
+		callee = compiler.NewNative(native)
+	}
+	if !callee.IsNative() {
+		return nil, fmt.Errorf("%w '%s'", ErrNotCallable, name)
+	}
+	native := callee.AsNative()
+	if len(args) < native.MinArgs || len(args) > native.MaxArgs {
+		return nil, fmt.Errorf("%w to '%s'", ErrArity, name)
+	}
+	values := make([]compiler.Value, len(args))
+	for j, arg := range args {
+		value, err := toValue(reflect.ValueOf(arg), element(name, j), make(map[reference]bool))
+		if err != nil {
+			return nil, err
+		}
+		values[j] = value
+	}
+	result, err := native.Call(values)
+	if err != nil {
+		return nil, fmt.Errorf("script: %s: %w", name, err)
+	}
+	return natural(result, name)
+}
+
+func (i *instance) Run(ctx context.Context) error {
+	return i.vm.RunContext(ctx)
+}
@@ -0,0 +1,191 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/lukibw/abc/vm"
+)
+
+func newVM(t *testing.T, source string, options ...vm.Option) VM {
+	t.Helper()
+	p, err := Compile(source)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	v, err := p.VM(options...)
+	if err != nil {
+		t.Fatalf("VM() error = %v", err)
+	}
+	return v
+}
+
+func TestGlobals(t *testing.T) {
+	v := newVM(t, "var total = price * count;")
+	if err := v.Set("price", 3); err != nil {
+		t.Fatalf("Set(price) error = %v", err)
+	}
+	if err := v.Set("count", 4); err != nil {
+		t.Fatalf("Set(count) error = %v", err)
+	}
+	if err := v.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	total, err := v.Get("total")
+	if err != nil {
+		t.Fatalf("Get(total) error = %v", err)
+	}
+	if total != int64(12) {
+		t.Fatalf("total = %#v, want %#v", total, int64(12))
+	}
+	if _, err = v.Get("missing"); !errors.Is(err, ErrUndefinedGlobal) {
+		t.Fatalf("Get(missing) error = %v, want %v", err, ErrUndefinedGlobal)
+	}
+}
+
+func TestRegister(t *testing.T) {
+	var output strings.Builder
+	v := newVM(t, `print greet("abc", 2);`, vm.WithOutput(&output))
+	err := v.Register("greet", func(args ...any) (any, error) {
+		return strings.Repeat("hello "+args[0].(string)+" ", int(args[1].(int64))), nil
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	if err = v.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got, want := output.String(), "hello abc hello abc \n"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
+
+func TestRegisterError(t *testing.T) {
+	v := newVM(t, `print fail();`)
+	err := v.Register("fail", func(args ...any) (any, error) {
+		return nil, errors.New("out of stock")
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	err = v.Run(context.Background())
+	var e *vm.Error
+	if !errors.As(err, &e) || e.Kind != vm.ErrNative || !strings.Contains(err.Error(), "out of stock") {
+		t.Fatalf("Run() error = %v, want a native error", err)
+	}
+}
+
+func TestCall(t *testing.T) {
+	v := newVM(t, "var toInt = int; var n = 1;")
+	if err := v.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	err := v.Register("sum", func(args ...any) (any, error) {
+		total := int64(0)
+		for _, a := range args {
+			total += a.(int64)
+		}
+		return total, nil
+	})
+	if err != nil {
+		t.Fatalf("Register() error = %v", err)
+	}
+	tests := []struct {
+		name string
+		args []any
+		want any
+		err  string
+	}{
+		{"sum", []any{1, 2, 3}, int64(6), ""},
+		{"toInt", []any{"42"}, int64(42), ""},
+		{"float", []any{1}, float64(1), ""},
+		{"int", []any{"x"}, nil, "script: int: cannot convert string x to int"},
+		{"int", []any{1, 2}, nil, ErrArity.Error()},
+		{"n", nil, nil, ErrNotCallable.Error()},
+		{"missing", nil, nil, ErrUndefinedGlobal.Error()},
+		{"sum", []any{make(chan int)}, nil, "sum[0]: cannot convert chan int"},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s%v", tt.name, tt.args), func(t *testing.T) {
+			got, err := v.Call(tt.name, tt.args...)
+			switch {
+			case tt.err == "" && err != nil:
+				t.Fatalf("Call() error = %v", err)
+			case tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)):
+				t.Fatalf("Call() error = %v, want %q", err, tt.err)
+			case got != tt.want:
+				t.Fatalf("Call() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentVMs(t *testing.T) {
+	p, err := Compile(`
+var total = 0n;
+for (var i = 0; i < n; i = i + 1) {
+  switch (i % 3) {
+    case 0: total = total + bigint(i);
+    case 1: total = total + match (pair) { [_, {v}] if v > 5 => 2n, _ => 1n };
+    default: total = total + 0n;
+  }
+}
+var price = decimal(n, 2) * decimal("1.5", 2);
+`)
+	if err != nil {
+		t.Fatalf("Compile() error = %v", err)
+	}
+	var wg sync.WaitGroup
+	errs := make([]error, 8)
+	for j := range errs {
+		wg.Add(1)
+		go func(j int) {
+			defer wg.Done()
+			errs[j] = func() error {
+				v, err := p.VM()
+				if err != nil {
+					return err
+				}
+				if err = v.Set("n", 100+j); err != nil {
+					return err
+				}
+				if err = v.Set("pair", []any{j, map[string]int{"v": j}}); err != nil {
+					return err
+				}
+				if err = v.Run(context.Background()); err != nil {
+					return err
+				}
+				var total int64
+				if err = v.Decode("total", &total); err != nil {
+					return err
+				}
+				// Multiples of 3 below n, plus 1 or 2 for each i % 3 == 1.
+				want := int64(0)
+				for i := 0; i < 100+j; i++ {
+					switch {
+					case i%3 == 0:
+						want += int64(i)
+					case i%3 == 1 && j > 5:
+						want += 2
+					case i%3 == 1:
+						want++
+					}
+				}
+				if total != want {
+					return fmt.Errorf("total = %d, want %d", total, want)
+				}
+				return nil
+			}()
+		}(j)
+	}
+	wg.Wait()
+	for j, err := range errs {
+		if err != nil {
+			t.Errorf("VM %d: %v", j, err)
+		}
+	}
+}
@@ -0,0 +1,518 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"reflect"
+	"strings"
+
+	"github.com/lukibw/abc/compiler"
+)
+
+var ErrConversion = errors.New("script: conversion error")
+
+// ConversionError describes a value that could not be converted, Path
+// locates it inside the converted value, e.g. "items[2].name".
+type ConversionError struct {
+	Path   string
+	Reason string
+}
+
+func (e *ConversionError) Error() string {
+	if e.Path == "" {
+		return fmt.Sprintf("script: %s", e.Reason)
+	}
+	return fmt.Sprintf("script: %s: %s", e.Path, e.Reason)
+}
+
+func (e *ConversionError) Unwrap() error {
+	return ErrConversion
+}
+
+func conversionError(path string, format string, a ...any) error {
+	return &ConversionError{path, fmt.Sprintf(format, a...)}
+}
+
+func field(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func element(path string, i any) string {
+	return fmt.Sprintf("%s[%v]", path, i)
+}
+
+// ToValue converts a Go value to a script value. Bools, numbers and strings
+// map to their script counterparts, slices and arrays become lists, maps with
+// string keys and structs become objects, and nil pointers become nil.
+// Unsigned integers beyond the range of int64 become bigints. The
+// fields of embedded structs are promoted to the object of the outer struct.
+// A value referring to itself cannot be converted.
+func ToValue(v any) (compiler.Value, error) {
+	return toValue(reflect.ValueOf(v), "", make(map[reference]bool))
+}
+
+// reference identifies a pointer, map or slice being converted, so that a
+// value leading back to it is reported instead of recursing forever.
+type reference struct {
+	ptr    uintptr
+	t      reflect.Type
+	length int
+}
+
+// enter marks the reference v as being converted, it fails when v is
+// already being converted further up.
+func enter(v reflect.Value, path string, seen map[reference]bool) (reference, error) {
+	r := reference{v.Pointer(), v.Type(), 0}
+	if v.Kind() == reflect.Slice {
+		r.length = v.Len()
+	}
+	if seen[r] {
+		return r, conversionError(path, "cannot convert %s to a script value, it contains itself", v.Type())
+	}
+	seen[r] = true
+	return r, nil
+}
+
+var (
+	bigIntType  = reflect.TypeOf(big.Int{})
+	decimalType = reflect.TypeOf(compiler.Decimal{})
+)
+
+func toValue(v reflect.Value, path string, seen map[reference]bool) (compiler.Value, error) {
+	if !v.IsValid() {
+		return compiler.NewNil(), nil
+	}
+	switch v.Type() {
+	case bigIntType:
+		n := v.Interface().(big.Int)
+		return compiler.NewBigInteger(new(big.Int).Set(&n)), nil
+	case decimalType:
+		d := v.Interface().(compiler.Decimal)
+		return compiler.NewDecimal(&d), nil
+	}
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.IsNil() {
+			return compiler.NewNil(), nil
+		}
+		return toValue(v.Elem(), path, seen)
+	case reflect.Pointer:
+		if v.IsNil() {
+			return compiler.NewNil(), nil
+		}
+		r, err := enter(v, path, seen)
+		if err != nil {
+			return compiler.Value{}, err
+		}
+		defer delete(seen, r)
+		return toValue(v.Elem(), path, seen)
+	case reflect.Bool:
+		return compiler.NewBoolean(v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return compiler.NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > math.MaxInt64 {
+			return compiler.NewBigInteger(new(big.Int).SetUint64(v.Uint())), nil
+		}
+		return compiler.NewInteger(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return compiler.NewNumber(v.Float()), nil
+	case reflect.String:
+		return compiler.NewString(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice {
+			if v.IsNil() {
+				return compiler.NewNil(), nil
+			}
+			r, err := enter(v, path, seen)
+			if err != nil {
+				return compiler.Value{}, err
+			}
+			defer delete(seen, r)
+		}
+		items := make([]compiler.Value, v.Len())
+		for i := range items {
+			item, err := toValue(v.Index(i), element(path, i), seen)
+			if err != nil {
+				return compiler.Value{}, err
+			}
+			items[i] = item
+		}
+		return compiler.NewList(items), nil
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return compiler.Value{}, conversionError(path, "cannot convert %s to a script value, map keys must be strings", v.Type())
+		}
+		if v.IsNil() {
+			return compiler.NewNil(), nil
+		}
+		r, err := enter(v, path, seen)
+		if err != nil {
+			return compiler.Value{}, err
+		}
+		defer delete(seen, r)
+		fields := make(map[string]compiler.Value, v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			name := iter.Key().String()
+			value, err := toValue(iter.Value(), field(path, name), seen)
+			if err != nil {
+				return compiler.Value{}, err
+			}
+			fields[name] = value
+		}
+		return compiler.NewObject(fields), nil
+	case reflect.Struct:
+		fields := make(map[string]compiler.Value)
+		for _, f := range structFields(v.Type()) {
+			fv, ok := fieldByIndex(v, f.index)
+			if !ok {
+				// The field is promoted from a nil embedded pointer.
+				continue
+			}
+			value, err := toValue(fv, field(path, f.name), seen)
+			if err != nil {
+				return compiler.Value{}, err
+			}
+			fields[f.name] = value
+		}
+		return compiler.NewObject(fields), nil
+	default:
+		return compiler.Value{}, conversionError(path, "cannot convert %s to a script value", v.Type())
+	}
+}
+
+type structField struct {
+	name  string
+	index []int
+	depth int
+}
+
+// structFields lists the exported fields of t under their script names,
+// which is the `abc:"name"` tag if present and the field name otherwise.
+// Fields tagged `abc:"-"` are skipped. The fields of untagged embedded
+// structs are promoted like in Go: a shallower field hides deeper ones of
+// the same name and fields of the same name at the same depth hide each
+// other.
+func structFields(t reflect.Type) []structField {
+	all := collectFields(t, nil, make(map[reflect.Type]bool))
+	depths := make(map[string]int)
+	counts := make(map[string]int)
+	for _, f := range all {
+		depth, ok := depths[f.name]
+		switch {
+		case !ok || f.depth < depth:
+			depths[f.name] = f.depth
+			counts[f.name] = 1
+		case f.depth == depth:
+			counts[f.name]++
+		}
+	}
+	var fields []structField
+	for _, f := range all {
+		if f.depth == depths[f.name] && counts[f.name] == 1 {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
+
+func collectFields(t reflect.Type, index []int, visiting map[reflect.Type]bool) []structField {
+	visiting[t] = true
+	defer delete(visiting, t)
+	var fields []structField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag, tagged := f.Tag.Lookup("abc")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		fieldIndex := append(index[:len(index):len(index)], i)
+		if f.Anonymous && name == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Pointer {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if !visiting[embedded] {
+					fields = append(fields, collectFields(embedded, fieldIndex, visiting)...)
+				}
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if !tagged || name == "" {
+			name = f.Name
+		}
+		fields = append(fields, structField{name, fieldIndex, len(index)})
+	}
+	return fields
+}
+
+// fieldByIndex returns the field of the struct v at index, it reports false
+// when the path to the field goes through a nil embedded pointer.
+func fieldByIndex(v reflect.Value, index []int) (reflect.Value, bool) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				return reflect.Value{}, false
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, true
+}
+
+// settableField returns the field of the struct v at index, allocating the
+// nil embedded pointers on the way.
+func settableField(v reflect.Value, index []int, path string) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && v.Kind() == reflect.Pointer {
+			if v.IsNil() {
+				if !v.CanSet() {
+					return reflect.Value{}, conversionError(path, "cannot set field of nil embedded pointer to unexported %s", v.Type().Elem())
+				}
+				v.Set(reflect.New(v.Type().Elem()))
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+	return v, nil
+}
+
+// FromValue stores the script value v in the Go value pointed to by target,
+// converting it by the rules of ToValue in reverse. Decoding into an empty
+// interface yields int64, float64, *big.Int, *compiler.Decimal, string, bool,
+// []any, map[string]any or nil.
+func FromValue(v compiler.Value, target any) error {
+	t := reflect.ValueOf(target)
+	if t.Kind() != reflect.Pointer || t.IsNil() {
+		return conversionError("", "cannot decode into %T, target must be a non-nil pointer", target)
+	}
+	return fromValue(v, t.Elem(), "")
+}
+
+func fromValue(v compiler.Value, target reflect.Value, path string) error {
+	mismatch := func() error {
+		return conversionError(path, "cannot convert %s to %s", v.Type(), target.Type())
+	}
+	switch target.Type() {
+	case bigIntType:
+		if !v.IsBigInteger() && !v.IsInteger() {
+			return mismatch()
+		}
+		if v.IsInteger() {
+			target.Set(reflect.ValueOf(*big.NewInt(v.AsInteger())))
+		} else {
+			target.Set(reflect.ValueOf(*new(big.Int).Set(v.AsBigInteger())))
+		}
+		return nil
+	case decimalType:
+		if !v.IsDecimal() {
+			return mismatch()
+		}
+		target.Set(reflect.ValueOf(*v.AsDecimal()))
+		return nil
+	}
+	switch target.Kind() {
+	case reflect.Interface:
+		if target.NumMethod() != 0 {
+			return mismatch()
+		}
+		value, err := natural(v, path)
+		if err != nil {
+			return err
+		}
+		if value == nil {
+			target.Set(reflect.Zero(target.Type()))
+		} else {
+			target.Set(reflect.ValueOf(value))
+		}
+		return nil
+	case reflect.Pointer:
+		if v.IsNil() {
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		elem := reflect.New(target.Type().Elem())
+		if err := fromValue(v, elem.Elem(), path); err != nil {
+			return err
+		}
+		target.Set(elem)
+		return nil
+	case reflect.Bool:
+		if !v.IsBoolean() {
+			return mismatch()
+		}
+		target.SetBool(v.AsBoolean())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if !v.IsNumber() {
+			return mismatch()
+		}
+		n, ok := integer(v)
+		if !ok || !n.IsInt64() || target.OverflowInt(n.Int64()) {
+			return conversionError(path, "cannot convert %s to %s", v, target.Type())
+		}
+		target.SetInt(n.Int64())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if !v.IsNumber() {
+			return mismatch()
+		}
+		n, ok := integer(v)
+		if !ok || !n.IsUint64() || target.OverflowUint(n.Uint64()) {
+			return conversionError(path, "cannot convert %s to %s", v, target.Type())
+		}
+		target.SetUint(n.Uint64())
+	case reflect.Float32, reflect.Float64:
+		if !v.IsNumber() {
+			return mismatch()
+		}
+		f := v.AsNumber()
+		if target.OverflowFloat(f) || (math.IsInf(f, 0) && !v.IsFloat()) {
+			return conversionError(path, "cannot convert %s to %s", v, target.Type())
+		}
+		target.SetFloat(f)
+	case reflect.String:
+		if !v.IsString() {
+			return mismatch()
+		}
+		target.SetString(v.AsString())
+	case reflect.Slice:
+		if v.IsNil() {
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		if !v.IsList() {
+			return mismatch()
+		}
+		items := v.AsList()
+		slice := reflect.MakeSlice(target.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := fromValue(item, slice.Index(i), element(path, i)); err != nil {
+				return err
+			}
+		}
+		target.Set(slice)
+	case reflect.Array:
+		if !v.IsList() {
+			return mismatch()
+		}
+		items := v.AsList()
+		if len(items) != target.Len() {
+			return conversionError(path, "cannot convert list of length %d to %s", len(items), target.Type())
+		}
+		for i, item := range items {
+			if err := fromValue(item, target.Index(i), element(path, i)); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if target.Type().Key().Kind() != reflect.String {
+			return conversionError(path, "cannot decode into %s, map keys must be strings", target.Type())
+		}
+		if v.IsNil() {
+			target.Set(reflect.Zero(target.Type()))
+			return nil
+		}
+		if !v.IsObject() {
+			return mismatch()
+		}
+		fields := v.AsObject()
+		m := reflect.MakeMapWithSize(target.Type(), len(fields))
+		for name, value := range fields {
+			elem := reflect.New(target.Type().Elem()).Elem()
+			if err := fromValue(value, elem, field(path, name)); err != nil {
+				return err
+			}
+			m.SetMapIndex(reflect.ValueOf(name).Convert(target.Type().Key()), elem)
+		}
+		target.Set(m)
+	case reflect.Struct:
+		if !v.IsObject() {
+			return mismatch()
+		}
+		fields := v.AsObject()
+		for _, f := range structFields(target.Type()) {
+			value, ok := fields[f.name]
+			if !ok {
+				continue
+			}
+			fv, err := settableField(target, f.index, field(path, f.name))
+			if err != nil {
+				return err
+			}
+			if err = fromValue(value, fv, field(path, f.name)); err != nil {
+				return err
+			}
+		}
+	default:
+		return conversionError(path, "cannot decode into %s", target.Type())
+	}
+	return nil
+}
+
+// integer returns the exact value of a number without a fraction.
+func integer(v compiler.Value) (*big.Int, bool) {
+	if v.IsInteger() {
+		return big.NewInt(v.AsInteger()), true
+	}
+	r, ok := compiler.Rat(v)
+	if !ok || !r.IsInt() {
+		return nil, false
+	}
+	return r.Num(), true
+}
+
+// natural converts v to the Go type closest to its script kind.
+func natural(v compiler.Value, path string) (any, error) {
+	switch {
+	case v.IsNil():
+		return nil, nil
+	case v.IsBoolean():
+		return v.AsBoolean(), nil
+	case v.IsInteger():
+		return v.AsInteger(), nil
+	case v.IsFloat():
+		return v.AsNumber(), nil
+	case v.IsBigInteger():
+		return new(big.Int).Set(v.AsBigInteger()), nil
+	case v.IsDecimal():
+		return v.AsDecimal(), nil
+	case v.IsString():
+		return v.AsString(), nil
+	case v.IsList():
+		items := v.AsList()
+		values := make([]any, len(items))
+		for i, item := range items {
+			value, err := natural(item, element(path, i))
+			if err != nil {
+				return nil, err
+			}
+			values[i] = value
+		}
+		return values, nil
+	case v.IsObject():
+		fields := v.AsObject()
+		values := make(map[string]any, len(fields))
+		for name, f := range fields {
+			value, err := natural(f, field(path, name))
+			if err != nil {
+				return nil, err
+			}
+			values[name] = value
+		}
+		return values, nil
+	default:
+		return nil, conversionError(path, "cannot convert %s to a Go value", v)
+	}
+}
@@ -0,0 +1,262 @@
+package script
+
+import (
+	"context"
+	"errors"
+	"math"
+	"math/big"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/vm"
+)
+
+type Base struct {
+	ID   int
+	Name string
+}
+
+type order struct {
+	Base
+	Items    []item            `abc:"items"`
+	Labels   map[string]string `abc:"labels,omitempty"`
+	Total    *big.Int
+	Price    compiler.Decimal
+	Note     *string
+	Secret   string `abc:"-"`
+	internal int
+}
+
+type item struct {
+	SKU   string `abc:"sku"`
+	Count uint8
+	Ratio float64
+}
+
+func TestRoundTrip(t *testing.T) {
+	note := "fragile"
+	in := order{
+		Base:     Base{7, "first"},
+		Items:    []item{{"a-1", 2, 0.5}, {"b-2", 255, 1}},
+		Labels:   map[string]string{"region": "eu"},
+		Total:    new(big.Int).Lsh(big.NewInt(1), 70),
+		Price:    *compiler.RoundDecimal(big.NewRat(1999, 100), 2, compiler.RoundHalfEven),
+		Note:     &note,
+		Secret:   "hidden",
+		internal: 3,
+	}
+	v, err := ToValue(in)
+	if err != nil {
+		t.Fatalf("ToValue() error = %v", err)
+	}
+	fields := v.AsObject()
+	for _, name := range []string{"ID", "Name", "items", "labels", "Total", "Price", "Note"} {
+		if _, ok := fields[name]; !ok {
+			t.Errorf("field %s is missing from %s", name, v)
+		}
+	}
+	for _, name := range []string{"Base", "Items", "Labels", "Secret", "internal"} {
+		if _, ok := fields[name]; ok {
+			t.Errorf("field %s is present in %s", name, v)
+		}
+	}
+	var out order
+	if err = FromValue(v, &out); err != nil {
+		t.Fatalf("FromValue() error = %v", err)
+	}
+	in.Secret, in.internal = "", 0
+	if out.Total.Cmp(in.Total) != 0 || out.Price.String() != in.Price.String() {
+		t.Fatalf("FromValue() = %v %v, want %v %v", out.Total, out.Price.String(), in.Total, in.Price.String())
+	}
+	out.Total, out.Price = in.Total, in.Price
+	if !reflect.DeepEqual(out, in) {
+		t.Fatalf("FromValue() = %+v, want %+v", out, in)
+	}
+}
+
+type Named struct{ Name string }
+
+type Inner struct {
+	Named
+	Depth int
+}
+
+type outer struct {
+	*Inner
+	Name  string
+	Other struct{ Value int } `abc:"other"`
+}
+
+type ambiguous struct {
+	Base
+	Named
+}
+
+func TestEmbedded(t *testing.T) {
+	v, err := ToValue(outer{Inner: &Inner{Named{"inner"}, 2}, Name: "outer"})
+	if err != nil {
+		t.Fatalf("ToValue() error = %v", err)
+	}
+	if got, want := keys(v), []string{"Depth", "Name", "other"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("ToValue() fields = %v, want %v", got, want)
+	}
+	var out outer
+	if err = FromValue(v, &out); err != nil {
+		t.Fatalf("FromValue() error = %v", err)
+	}
+	if out.Inner == nil || out.Depth != 2 || out.Name != "outer" || out.Inner.Name != "" {
+		t.Fatalf("FromValue() = %+v", out)
+	}
+	if v, err = ToValue(outer{Name: "outer"}); err != nil {
+		t.Fatalf("ToValue() of nil embedded pointer error = %v", err)
+	}
+	if _, ok := v.AsObject()["Depth"]; ok {
+		t.Fatalf("ToValue() = %s, want no fields of the nil embedded pointer", v)
+	}
+	if v, err = ToValue(ambiguous{Base{1, "base"}, Named{"named"}}); err != nil {
+		t.Fatalf("ToValue() error = %v", err)
+	}
+	if _, ok := v.AsObject()["Name"]; ok {
+		t.Fatalf("ToValue() = %s, want the ambiguous Name dropped", v)
+	}
+}
+
+func keys(v compiler.Value) []string {
+	names := make([]string, 0)
+	for name := range v.AsObject() {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+func TestToValueErrors(t *testing.T) {
+	cyclic := &node{Value: 1}
+	cyclic.Next = &node{Value: 2, Next: cyclic}
+	m := map[string]any{}
+	m["self"] = m
+	s := []any{nil}
+	s[0] = s
+	tests := []struct {
+		name  string
+		value any
+		err   string
+	}{
+		{"channel", make(chan int), "cannot convert chan int"},
+		{"function field", struct{ F func() }{}, "F: cannot convert func()"},
+		{"map key", map[int]string{}, "map keys must be strings"},
+		{"pointer cycle", cyclic, "Next.Next: cannot convert *script.node to a script value, it contains itself"},
+		{"map cycle", m, "self: cannot convert map[string]interface {} to a script value, it contains itself"},
+		{"slice cycle", s, "[0]: cannot convert []interface {} to a script value, it contains itself"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := ToValue(tt.value)
+			if !errors.Is(err, ErrConversion) || !strings.Contains(err.Error(), tt.err) {
+				t.Fatalf("ToValue() error = %v, want %q", err, tt.err)
+			}
+		})
+	}
+}
+
+func TestToValueShared(t *testing.T) {
+	shared := &node{Value: 1}
+	v, err := ToValue([]*node{shared, shared})
+	if err != nil {
+		t.Fatalf("ToValue() error = %v", err)
+	}
+	if len(v.AsList()) != 2 {
+		t.Fatalf("ToValue() = %s", v)
+	}
+}
+
+func TestToValueUnsigned(t *testing.T) {
+	v, err := ToValue(map[string][]uint64{"counts": {1, 1 << 63}})
+	if err != nil {
+		t.Fatalf("ToValue() error = %v", err)
+	}
+	counts := v.AsObject()["counts"].AsList()
+	if !counts[0].IsInteger() || !counts[1].IsBigInteger() || counts[1].String() != "9223372036854775808" {
+		t.Fatalf("ToValue() = %s, want an integer and a bigint", v)
+	}
+	var out map[string][]uint64
+	if err = FromValue(v, &out); err != nil || out["counts"][1] != 1<<63 {
+		t.Fatalf("FromValue() = %v, %v", out, err)
+	}
+}
+
+func TestZeroDecimal(t *testing.T) {
+	type product struct{ Price compiler.Decimal }
+	var output strings.Builder
+	v := newVM(t, "print p.Price; print p.Price + 1; print -p.Price;", vm.WithOutput(&output))
+	if err := v.Set("p", product{}); err != nil {
+		t.Fatalf("Set() error = %v", err)
+	}
+	if err := v.Run(context.Background()); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got, want := output.String(), "0\n1\n0\n"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+	var out product
+	if err := v.Decode("p", &out); err != nil || out.Price.Sign() != 0 {
+		t.Fatalf("Decode() = %v, %v", out.Price.String(), err)
+	}
+}
+
+func TestFromValueFloats(t *testing.T) {
+	var f32 float32
+	if err := FromValue(compiler.NewNumber(1.5), &f32); err != nil || f32 != 1.5 {
+		t.Fatalf("FromValue(1.5) = %v, %v", f32, err)
+	}
+	if err := FromValue(compiler.NewNumber(1e300), &f32); !errors.Is(err, ErrConversion) {
+		t.Fatalf("FromValue(1e300) into float32 error = %v", err)
+	}
+	huge := compiler.NewBigInteger(new(big.Int).Lsh(big.NewInt(1), 1100))
+	var f64 float64
+	if err := FromValue(huge, &f64); !errors.Is(err, ErrConversion) {
+		t.Fatalf("FromValue(2**1100) into float64 error = %v", err)
+	}
+	if err := FromValue(compiler.NewNumber(math.Inf(1)), &f64); err != nil || !math.IsInf(f64, 1) {
+		t.Fatalf("FromValue(+Inf) = %v, %v", f64, err)
+	}
+}
+
+func TestFromValueIntegers(t *testing.T) {
+	big63 := compiler.NewBigInteger(new(big.Int).Lsh(big.NewInt(1), 63))
+	exact := compiler.NewBigInteger(big.NewInt(1<<62 + 1))
+	decimal := compiler.NewDecimal(compiler.RoundDecimal(big.NewRat(12, 1), 2, compiler.RoundHalfEven))
+	fraction := compiler.NewDecimal(compiler.RoundDecimal(big.NewRat(25, 2), 2, compiler.RoundHalfEven))
+	var i64 int64
+	if err := FromValue(exact, &i64); err != nil || i64 != 1<<62+1 {
+		t.Fatalf("FromValue(%s) = %d, %v", exact, i64, err)
+	}
+	var u64 uint64
+	if err := FromValue(big63, &u64); err != nil || u64 != 1<<63 {
+		t.Fatalf("FromValue(%s) = %d, %v", big63, u64, err)
+	}
+	if err := FromValue(big63, &i64); !errors.Is(err, ErrConversion) {
+		t.Fatalf("FromValue(%s) into int64 error = %v", big63, err)
+	}
+	var i8 int8
+	if err := FromValue(decimal, &i8); err != nil || i8 != 12 {
+		t.Fatalf("FromValue(%s) = %d, %v", decimal, i8, err)
+	}
+	if err := FromValue(fraction, &i8); !errors.Is(err, ErrConversion) {
+		t.Fatalf("FromValue(%s) error = %v", fraction, err)
+	}
+	if err := FromValue(compiler.NewInteger(-1), &u64); !errors.Is(err, ErrConversion) {
+		t.Fatalf("FromValue(-1) into uint64 error = %v", err)
+	}
+	if err := FromValue(compiler.NewNumber(2.5), &i64); !errors.Is(err, ErrConversion) {
+		t.Fatalf("FromValue(2.5) error = %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package transport
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Conn interface {
+	Read() ([]byte, error)
+	Write(v any) error
+}
+
+func New(r io.Reader, w io.Writer) Conn {
+	return &conn{bufio.NewReader(r), w, sync.Mutex{}}
+}
+
+type conn struct {
+	r     *bufio.Reader
+	w     io.Writer
+	mutex sync.Mutex
+}
+
+func (c *conn) Read() ([]byte, error) {
+	length := -1
+	for {
+		line, err := c.r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("transport: invalid header '%s'", line)
+		}
+		if strings.EqualFold(strings.TrimSpace(name), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(value)); err != nil {
+				return nil, fmt.Errorf("transport: invalid content length '%s'", value)
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("transport: missing content length")
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(c.r, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func (c *conn) Write(v any) error {
+	content, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if _, err = fmt.Fprintf(c.w, "Content-Length: %d\r\n\r\n", len(content)); err != nil {
+		return err
+	}
+	_, err = c.w.Write(content)
+	return err
+}
@@ -0,0 +1,218 @@
+package vm
+
+import (
+	"math"
+	"math/big"
+
+	"github.com/lukibw/abc/compiler"
+)
+
+// arithmetic holds the implementations of a binary operation for each kind
+// of number. The integer one reports false when the exact result does not
+// fit in 64 bits.
+type arithmetic struct {
+	integer func(x, y int64) (int64, bool)
+	float   func(x, y float64) float64
+	big     func(z, x, y *big.Int) *big.Int
+	rat     func(z, x, y *big.Rat) *big.Rat
+}
+
+var (
+	addition = arithmetic{
+		func(x, y int64) (int64, bool) {
+			z := x + y
+			return z, (z > x) == (y > 0)
+		},
+		func(x, y float64) float64 { return x + y },
+		(*big.Int).Add,
+		(*big.Rat).Add,
+	}
+	subtraction = arithmetic{
+		func(x, y int64) (int64, bool) {
+			z := x - y
+			return z, (z < x) == (y > 0)
+		},
+		func(x, y float64) float64 { return x - y },
+		(*big.Int).Sub,
+		(*big.Rat).Sub,
+	}
+	multiplication = arithmetic{
+		func(x, y int64) (int64, bool) {
+			if x == 0 || y == 0 {
+				return 0, true
+			}
+			z := x * y
+			return z, z/y == x && !(x == -1 && y == math.MinInt64) && !(y == -1 && x == math.MinInt64)
+		},
+		func(x, y float64) float64 { return x * y },
+		(*big.Int).Mul,
+		(*big.Rat).Mul,
+	}
+	// division truncates integers towards zero, the divisor of exact
+	// numbers must not be zero.
+	division = arithmetic{
+		func(x, y int64) (int64, bool) {
+			if x == math.MinInt64 && y == -1 {
+				return 0, false
+			}
+			return x / y, true
+		},
+		func(x, y float64) float64 { return x / y },
+		(*big.Int).Quo,
+		(*big.Rat).Quo,
+	}
+)
+
+// floorDivision and modulo round the quotient towards negative infinity, so
+// the remainder has the sign of the divisor and x == (x ~/ y) * y + x % y.
+// The divisor of exact numbers must not be zero.
+var (
+	floorDivision = arithmetic{
+		func(x, y int64) (int64, bool) {
+			if x == math.MinInt64 && y == -1 {
+				return 0, false
+			}
+			q := x / y
+			if x%y != 0 && (x < 0) != (y < 0) {
+				q--
+			}
+			return q, true
+		},
+		func(x, y float64) float64 { return math.Floor(x / y) },
+		floorBig,
+		func(z, x, y *big.Rat) *big.Rat {
+			q := new(big.Rat).Quo(x, y)
+			return z.SetInt(new(big.Int).Div(q.Num(), q.Denom()))
+		},
+	}
+	modulo = arithmetic{
+		func(x, y int64) (int64, bool) {
+			r := x % y
+			if r != 0 && (r < 0) != (y < 0) {
+				r += y
+			}
+			return r, true
+		},
+		func(x, y float64) float64 {
+			r := math.Mod(x, y)
+			if r != 0 && (r < 0) != (y < 0) {
+				r += y
+			}
+			return r
+		},
+		func(z, x, y *big.Int) *big.Int {
+			q := floorBig(new(big.Int), x, y)
+			return z.Sub(x, q.Mul(q, y))
+		},
+		func(z, x, y *big.Rat) *big.Rat {
+			q := new(big.Rat).Quo(x, y)
+			q.SetInt(new(big.Int).Div(q.Num(), q.Denom()))
+			return z.Sub(x, q.Mul(q, y))
+		},
+	}
+)
+
+func floorBig(z, x, y *big.Int) *big.Int {
+	// Div rounds towards negative infinity only for positive divisors.
+	if y.Sign() < 0 {
+		return z.Div(new(big.Int).Neg(x), new(big.Int).Neg(y))
+	}
+	return z.Div(x, y)
+}
+
+// powerInteger computes x to the non-negative power y by squaring, it
+// reports false when the result does not fit in 64 bits.
+func powerInteger(x, y int64) (int64, bool) {
+	z := int64(1)
+	ok := true
+	for y > 0 {
+		if y&1 == 1 {
+			if z, ok = multiplication.integer(z, x); !ok {
+				return 0, false
+			}
+		}
+		y >>= 1
+		if y > 0 {
+			if x, ok = multiplication.integer(x, x); !ok {
+				return 0, false
+			}
+		}
+	}
+	return z, true
+}
+
+func bigInteger(v compiler.Value) *big.Int {
+	if v.IsInteger() {
+		return big.NewInt(v.AsInteger())
+	}
+	return v.AsBigInteger()
+}
+
+// decimal returns the scale and rounding mode of a result of an operation on
+// a and b, at least one of which is a decimal.
+func decimal(a, b compiler.Value) (int, compiler.RoundingMode) {
+	if !a.IsDecimal() {
+		return b.AsDecimal().Scale(), b.AsDecimal().Mode()
+	}
+	scale := a.AsDecimal().Scale()
+	if b.IsDecimal() && b.AsDecimal().Scale() > scale {
+		scale = b.AsDecimal().Scale()
+	}
+	return scale, a.AsDecimal().Mode()
+}
+
+func isZero(v compiler.Value) bool {
+	switch {
+	case v.IsInteger():
+		return v.AsInteger() == 0
+	case v.IsBigInteger():
+		return v.AsBigInteger().Sign() == 0
+	case v.IsDecimal():
+		return v.AsDecimal().Sign() == 0
+	default:
+		return false
+	}
+}
+
+// integral converts a number without a fraction to an integer, leaving big
+// integers as they are. It reports false for decimals and for floats with a
+// fraction or out of the range of integers.
+func integral(v compiler.Value) (compiler.Value, bool) {
+	switch {
+	case v.IsInteger(), v.IsBigInteger():
+		return v, true
+	case v.IsFloat():
+		f := v.AsNumber()
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 {
+			return compiler.Value{}, false
+		}
+		return compiler.NewInteger(int64(f)), true
+	default:
+		return compiler.Value{}, false
+	}
+}
+
+// bitwise holds the implementations of a bitwise operation for integers and
+// big integers, both of which behave as two's complement numbers.
+type bitwise struct {
+	integer func(x, y int64) int64
+	big     func(z, x, y *big.Int) *big.Int
+}
+
+var (
+	bitAnd = bitwise{func(x, y int64) int64 { return x & y }, (*big.Int).And}
+	bitOr  = bitwise{func(x, y int64) int64 { return x | y }, (*big.Int).Or}
+	bitXor = bitwise{func(x, y int64) int64 { return x ^ y }, (*big.Int).Xor}
+)
+
+// shiftLeft reports false when bits would be shifted out of an integer.
+func shiftLeft(x int64, n uint64) (int64, bool) {
+	if x == 0 {
+		return 0, true
+	}
+	if n >= 64 {
+		return 0, false
+	}
+	z := x << n
+	return z, z>>n == x
+}
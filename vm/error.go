@@ -9,6 +9,27 @@ const (
 	ErrNumberOperands
 	ErrNumberOrStringOperands
 	ErrUndefinedVar
+	ErrInstructionLimit
+	ErrStackLimit
+	ErrStringLimit
+	ErrGlobalLimit
+	ErrCanceled
+	ErrObjectOperand
+	ErrUndefinedProperty
+	ErrIndexOperand
+	ErrIndexRange
+	ErrIndexable
+	ErrIntegerOverflow
+	ErrDivisionByZero
+	ErrMixedPrecision
+	ErrNotCallable
+	ErrArity
+	ErrNative
+	ErrExponent
+	ErrIntegerOperand
+	ErrIntegerOperands
+	ErrNegativeShift
+	ErrNoMatch
 )
 
 var errorMessages = map[ErrorKind]string{
@@ -16,6 +37,27 @@ var errorMessages = map[ErrorKind]string{
 	ErrNumberOperands:         "operands must be numbers",
 	ErrNumberOrStringOperands: "operands must be two numbers or two strings",
 	ErrUndefinedVar:           "undefined variable",
+	ErrInstructionLimit:       "instruction limit exceeded",
+	ErrStackLimit:             "stack depth limit exceeded",
+	ErrStringLimit:            "string length limit exceeded",
+	ErrGlobalLimit:            "global variable limit exceeded",
+	ErrCanceled:               "execution canceled",
+	ErrObjectOperand:          "only objects, lists and strings have properties",
+	ErrUndefinedProperty:      "undefined property",
+	ErrIndexOperand:           "invalid index",
+	ErrIndexRange:             "index out of range",
+	ErrIndexable:              "only lists, objects and strings can be indexed",
+	ErrIntegerOverflow:        "integer overflow",
+	ErrDivisionByZero:         "division by zero",
+	ErrMixedPrecision:         "floats cannot be mixed with bigints or decimals",
+	ErrNotCallable:            "only native functions can be called",
+	ErrArity:                  "wrong number of arguments",
+	ErrNative:                 "native function failed",
+	ErrExponent:               "bigints can only be raised to non-negative integers and decimals to integers",
+	ErrIntegerOperand:         "operand must be an integer",
+	ErrIntegerOperands:        "operands must be integers",
+	ErrNegativeShift:          "shift count must not be negative",
+	ErrNoMatch:                "no match arm matches the value",
 }
 
 func (k ErrorKind) String() string {
@@ -24,8 +66,21 @@ func (k ErrorKind) String() string {
 
 type Error struct {
 	Kind ErrorKind
+	Line int
+	Err  error
 }
 
 func (e *Error) Error() string {
-	return fmt.Sprintf("runtime error: %s", e.Kind)
+	if e.Line == 0 {
+		// The error comes from the host rather than from an instruction.
+		return fmt.Sprintf("runtime error: %s", e.Kind)
+	}
+	if e.Err != nil {
+		return fmt.Sprintf("[line %d] runtime error: %s: %s", e.Line, e.Kind, e.Err)
+	}
+	return fmt.Sprintf("[line %d] runtime error: %s", e.Line, e.Kind)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
 }
@@ -0,0 +1,41 @@
+package vm
+
+type Limits struct {
+	Instructions int
+	StackDepth   int
+	StringLength int
+	Globals      int
+}
+
+// WithLimits bounds the resources a script may use. A zero field leaves the
+// matching resource unlimited.
+func WithLimits(l Limits) Option {
+	return func(vm *vm) {
+		vm.limits = l
+	}
+}
+
+// globalFits reports whether the global name can be defined or assigned
+// without exceeding the limit on globals.
+func (vm *vm) globalFits(name string) bool {
+	_, ok := vm.globals[name]
+	return ok || vm.limits.Globals == 0 || len(vm.globals) < vm.limits.Globals
+}
+
+// checkInstructions is called before each instruction, so that the
+// instruction past the limit never runs.
+func (vm *vm) checkInstructions() error {
+	if vm.limits.Instructions > 0 && vm.executed >= vm.limits.Instructions {
+		return vm.newError(ErrInstructionLimit)
+	}
+	return nil
+}
+
+// checkStack is called after the instruction at offset, which is the one
+// that grew the stack past the limit.
+func (vm *vm) checkStack(offset int) error {
+	if vm.limits.StackDepth > 0 && len(vm.stack) > vm.limits.StackDepth {
+		return &Error{ErrStackLimit, vm.chunk.Lines[offset], nil}
+	}
+	return nil
+}
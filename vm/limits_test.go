@@ -0,0 +1,119 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+)
+
+func run(t *testing.T, source string, l Limits) (string, error) {
+	t.Helper()
+	var output strings.Builder
+	v, err := New(compiler.New(scanner.New([]byte(source))), WithLimits(l), WithOutput(&output))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	err = v.Run()
+	return output.String(), err
+}
+
+// checkLimit runs source under l and checks its output and that it fails
+// with the error kind want on line, or succeeds if line is 0.
+func checkLimit(t *testing.T, source string, l Limits, output string, want ErrorKind, line int) {
+	t.Helper()
+	got, err := run(t, source, l)
+	if got != output {
+		t.Errorf("output = %q, want %q", got, output)
+	}
+	if line == 0 {
+		if err != nil {
+			t.Fatalf("Run() error = %v", err)
+		}
+		return
+	}
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != want || e.Line != line {
+		t.Fatalf("Run() error = %v, want %v on line %d", err, want, line)
+	}
+}
+
+func TestInstructionLimit(t *testing.T) {
+	// CONSTANT, PRINT, CONSTANT, PRINT and RETURN: a limit of n runs
+	// exactly n instructions.
+	source := "print 1;\nprint 2;"
+	tests := []struct {
+		limit  int
+		output string
+		line   int
+	}{
+		{1, "", 1},
+		{2, "1\n", 2},
+		{3, "1\n", 2},
+		{4, "1\n2\n", 2},
+		{5, "1\n2\n", 0},
+		{0, "1\n2\n", 0},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(tt.limit), func(t *testing.T) {
+			checkLimit(t, source, Limits{Instructions: tt.limit}, tt.output, ErrInstructionLimit, tt.line)
+		})
+	}
+}
+
+func TestStackLimit(t *testing.T) {
+	// The innermost addition has four values on the stack.
+	source := "print 1;\nprint 1 + (2 + (3 + 4));"
+	checkLimit(t, source, Limits{StackDepth: 4}, "1\n10\n", 0, 0)
+	checkLimit(t, source, Limits{StackDepth: 3}, "1\n", ErrStackLimit, 2)
+	checkLimit(t, "{\n  var a = 1;\n  var b = 2;\n  var c = 3;\n}", Limits{StackDepth: 2}, "", ErrStackLimit, 4)
+}
+
+func TestStringLimit(t *testing.T) {
+	tests := []struct {
+		name   string
+		source string
+		output string
+		line   int
+	}{
+		{"concatenation at limit", "print \"abcd\" + \"ef\";", "abcdef\n", 0},
+		{"concatenation past limit", "print \"abcd\" + \"efg\";", "", 1},
+		{"growth through +", "var s = \"a\";\nwhile (true) {\n  s = s + s;\n  print s;\n}", "aa\naaaa\n", 3},
+		{"interpolation at limit", "var s = \"ab\";\nprint \"${s}-${s}!\";", "ab-ab!\n", 0},
+		{"interpolation past limit", "var s = \"abc\";\nprint \"${s}-${s}!\";", "", 2},
+		{"growth through interpolation", "var s = \"a\";\nwhile (true) {\n  s = \"${s}${s}\";\n  print s;\n}", "aa\naaaa\n", 3},
+		{"interpolated number", "var n = 1234567;\nprint \"${n}\";", "", 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkLimit(t, tt.source, Limits{StringLength: 6}, tt.output, ErrStringLimit, tt.line)
+		})
+	}
+}
+
+func TestSetGlobalLimit(t *testing.T) {
+	v, err := New(compiler.New(scanner.New([]byte("var a = 1;"))), WithLimits(Limits{Globals: 2}))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if err = v.SetGlobal("b", compiler.NewInteger(2)); err != nil {
+		t.Fatalf("SetGlobal(b) error = %v", err)
+	}
+	if err = v.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if err = v.SetGlobal("a", compiler.NewInteger(3)); err != nil {
+		t.Fatalf("SetGlobal(a) error = %v", err)
+	}
+	err = v.SetGlobal("c", compiler.NewInteger(4))
+	var e *Error
+	if !errors.As(err, &e) || e.Kind != ErrGlobalLimit {
+		t.Fatalf("SetGlobal(c) error = %v, want %v", err, ErrGlobalLimit)
+	}
+	if _, ok := v.Global("c"); ok {
+		t.Fatalf("Global(c) is defined past the limit")
+	}
+}
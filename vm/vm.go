@@ -1,34 +1,155 @@
 package vm
 
 import (
+	"context"
 	"fmt"
+	"io"
 	"log"
+	"math"
+	"math/big"
+	"os"
 	"strings"
 	"sync"
+	"unicode/utf8"
 
 	"github.com/lukibw/abc/compiler"
 )
 
 type VM interface {
 	Run() error
+	RunContext(ctx context.Context) error
+	Chunk() *compiler.Chunk
+	Offset() int
+	Stack() []compiler.Value
+	Peek(distance int) compiler.Value
+	Globals() map[string]compiler.Value
+	Global(name string) (compiler.Value, bool)
+	SetGlobal(name string, v compiler.Value) error
 }
 
-func New(c compiler.Compiler, logger *log.Logger) (VM, error) {
+type Hook func(vm VM) error
+
+type Option func(vm *vm)
+
+func WithHook(h Hook) Option {
+	return func(vm *vm) {
+		vm.hooks = append(vm.hooks, h)
+	}
+}
+
+// WithAfterHook adds a hook called after each instruction is executed.
+func WithAfterHook(h Hook) Option {
+	return func(vm *vm) {
+		vm.afterHooks = append(vm.afterHooks, h)
+	}
+}
+
+func WithTrace(logger *log.Logger) Option {
+	return func(vm *vm) {
+		vm.logger = logger
+		vm.hooks = append(vm.hooks, vm.trace)
+	}
+}
+
+func WithOutput(w io.Writer) Option {
+	return WithPrint(func(v compiler.Value) error {
+		_, err := fmt.Fprintln(w, v)
+		return err
+	})
+}
+
+func WithPrint(f func(v compiler.Value) error) Option {
+	return func(vm *vm) {
+		vm.print = f
+	}
+}
+
+func WithoutPrint() Option {
+	return WithPrint(func(compiler.Value) error {
+		return nil
+	})
+}
+
+func New(c compiler.Compiler, options ...Option) (VM, error) {
 	chunk, err := c.Run()
 	if err != nil {
 		return nil, err
 	}
-	return &vm{false, 0, logger, chunk, make([]compiler.Value, 0), sync.Mutex{}, make(map[string]compiler.Value)}, nil
+	vm := &vm{
+		chunk:   chunk,
+		stack:   make([]compiler.Value, 0),
+		globals: make(map[string]compiler.Value),
+		hooks:   make([]Hook, 0),
+	}
+	WithOutput(os.Stdout)(vm)
+	for _, o := range options {
+		o(vm)
+	}
+	return vm, nil
 }
 
 type vm struct {
-	isEnd   bool
-	i       int
-	logger  *log.Logger
-	chunk   *compiler.Chunk
-	stack   []compiler.Value
-	mutex   sync.Mutex
-	globals map[string]compiler.Value
+	isEnd      bool
+	i          int
+	logger     *log.Logger
+	chunk      *compiler.Chunk
+	stack      []compiler.Value
+	mutex      sync.Mutex
+	globals    map[string]compiler.Value
+	hooks      []Hook
+	afterHooks []Hook
+	print      func(v compiler.Value) error
+	limits     Limits
+	executed   int
+}
+
+func (vm *vm) Chunk() *compiler.Chunk {
+	return vm.chunk
+}
+
+func (vm *vm) Offset() int {
+	return vm.i
+}
+
+func (vm *vm) Stack() []compiler.Value {
+	vm.mutex.Lock()
+	defer vm.mutex.Unlock()
+	stack := make([]compiler.Value, len(vm.stack))
+	copy(stack, vm.stack)
+	return stack
+}
+
+// Peek returns the value distance slots below the top of the stack without
+// copying the stack.
+func (vm *vm) Peek(distance int) compiler.Value {
+	return vm.peek(distance)
+}
+
+func (vm *vm) Globals() map[string]compiler.Value {
+	globals := make(map[string]compiler.Value, len(vm.globals))
+	for name, value := range vm.globals {
+		globals[name] = value
+	}
+	return globals
+}
+
+func (vm *vm) Global(name string) (compiler.Value, bool) {
+	v, ok := vm.globals[name]
+	return v, ok
+}
+
+// SetGlobal defines or assigns a global from the host. New globals count
+// towards the same limit as the globals defined by the script.
+func (vm *vm) SetGlobal(name string, v compiler.Value) error {
+	if !vm.globalFits(name) {
+		return &Error{Kind: ErrGlobalLimit}
+	}
+	vm.globals[name] = v
+	return nil
+}
+
+func (vm *vm) newError(k ErrorKind) error {
+	return &Error{k, vm.chunk.Lines[vm.i], nil}
 }
 
 func (vm *vm) push(v compiler.Value) {
@@ -51,23 +172,200 @@ func (vm *vm) peek(distance int) compiler.Value {
 	return vm.stack[len(vm.stack)-1-distance]
 }
 
-func (vm *vm) binary(f func(x, y float64) float64) error {
-	if !vm.peek(0).IsNumber() || !vm.peek(1).IsNumber() {
-		return &Error{ErrNumberOperands}
+// binary applies an arithmetic operation to the two numbers on top of the
+// stack. Integers stay integers, decimals absorb integers and big integers,
+// big integers absorb integers, and anything else is promoted to float.
+// Floats do not mix with big integers or decimals.
+func (vm *vm) binary(op arithmetic) error {
+	b := vm.peek(0)
+	a := vm.peek(1)
+	if !a.IsNumber() || !b.IsNumber() {
+		return vm.newError(ErrNumberOperands)
 	}
-	b := vm.pop().AsNumber()
-	a := vm.pop().AsNumber()
-	vm.push(compiler.NewNumber(f(a, b)))
+	var result compiler.Value
+	switch {
+	case a.IsInteger() && b.IsInteger():
+		n, ok := op.integer(a.AsInteger(), b.AsInteger())
+		if !ok {
+			return vm.newError(ErrIntegerOverflow)
+		}
+		result = compiler.NewInteger(n)
+	case a.IsFloat() || b.IsFloat():
+		if a.IsExact() && !a.IsInteger() || b.IsExact() && !b.IsInteger() {
+			return vm.newError(ErrMixedPrecision)
+		}
+		result = compiler.NewNumber(op.float(a.AsNumber(), b.AsNumber()))
+	case a.IsDecimal() || b.IsDecimal():
+		x, _ := compiler.Rat(a)
+		y, _ := compiler.Rat(b)
+		scale, mode := decimal(a, b)
+		result = compiler.NewDecimal(compiler.RoundDecimal(op.rat(new(big.Rat), x, y), scale, mode))
+	default:
+		result = compiler.NewBigInteger(op.big(new(big.Int), bigInteger(a), bigInteger(b)))
+	}
+	vm.pop()
+	vm.pop()
+	vm.push(result)
+	return nil
+}
+
+// power raises the number below the top of the stack to the number on top.
+// An integer raised to a negative integer gives a float. Big integers and
+// decimals can only be raised to integers, big integers to non-negative ones.
+func (vm *vm) power() error {
+	b := vm.peek(0)
+	a := vm.peek(1)
+	if !a.IsNumber() || !b.IsNumber() {
+		return vm.newError(ErrNumberOperands)
+	}
+	var result compiler.Value
+	switch {
+	case a.IsInteger() && b.IsInteger() && b.AsInteger() >= 0:
+		n, ok := powerInteger(a.AsInteger(), b.AsInteger())
+		if !ok {
+			return vm.newError(ErrIntegerOverflow)
+		}
+		result = compiler.NewInteger(n)
+	case a.IsFloat() || b.IsFloat():
+		if a.IsExact() && !a.IsInteger() || b.IsExact() && !b.IsInteger() {
+			return vm.newError(ErrMixedPrecision)
+		}
+		result = compiler.NewNumber(math.Pow(a.AsNumber(), b.AsNumber()))
+	case a.IsInteger() && b.IsInteger():
+		result = compiler.NewNumber(math.Pow(a.AsNumber(), b.AsNumber()))
+	case !b.IsInteger() && !(b.IsBigInteger() && b.AsBigInteger().IsInt64()):
+		return vm.newError(ErrExponent)
+	case !a.IsDecimal():
+		y := bigInteger(b).Int64()
+		if y < 0 {
+			return vm.newError(ErrExponent)
+		}
+		result = compiler.NewBigInteger(new(big.Int).Exp(bigInteger(a), big.NewInt(y), nil))
+	default:
+		y := bigInteger(b).Int64()
+		d := a.AsDecimal()
+		if d.Sign() == 0 && y < 0 {
+			return vm.newError(ErrDivisionByZero)
+		}
+		x := d.Rat()
+		n := new(big.Int).Exp(x.Num(), big.NewInt(abs(y)), nil)
+		m := new(big.Int).Exp(x.Denom(), big.NewInt(abs(y)), nil)
+		if y < 0 {
+			n, m = m, n
+		}
+		result = compiler.NewDecimal(compiler.RoundDecimal(new(big.Rat).SetFrac(n, m), d.Scale(), d.Mode()))
+	}
+	vm.pop()
+	vm.pop()
+	vm.push(result)
+	return nil
+}
+
+// bitwise applies a bitwise operation to the two integral numbers on top of
+// the stack, the result is a big integer when either of them is one.
+func (vm *vm) bitwise(op bitwise) error {
+	b, bok := integral(vm.peek(0))
+	a, aok := integral(vm.peek(1))
+	if !aok || !bok {
+		return vm.newError(ErrIntegerOperands)
+	}
+	var result compiler.Value
+	if a.IsInteger() && b.IsInteger() {
+		result = compiler.NewInteger(op.integer(a.AsInteger(), b.AsInteger()))
+	} else {
+		result = compiler.NewBigInteger(op.big(new(big.Int), bigInteger(a), bigInteger(b)))
+	}
+	vm.pop()
+	vm.pop()
+	vm.push(result)
+	return nil
+}
+
+// shift shifts the integral number below the top of the stack by the number
+// of bits on top. Shifting an integer left fails when bits would be lost,
+// shifting right keeps the sign.
+func (vm *vm) shift(left bool) error {
+	b, bok := integral(vm.peek(0))
+	a, aok := integral(vm.peek(1))
+	if !aok || !bok {
+		return vm.newError(ErrIntegerOperands)
+	}
+	if bigInteger(b).Sign() < 0 {
+		return vm.newError(ErrNegativeShift)
+	}
+	if !bigInteger(b).IsUint64() {
+		return vm.newError(ErrIntegerOverflow)
+	}
+	n := bigInteger(b).Uint64()
+	var result compiler.Value
+	switch {
+	case a.IsBigInteger() && left:
+		result = compiler.NewBigInteger(new(big.Int).Lsh(a.AsBigInteger(), uint(n)))
+	case a.IsBigInteger():
+		result = compiler.NewBigInteger(new(big.Int).Rsh(a.AsBigInteger(), uint(n)))
+	case left:
+		z, ok := shiftLeft(a.AsInteger(), n)
+		if !ok {
+			return vm.newError(ErrIntegerOverflow)
+		}
+		result = compiler.NewInteger(z)
+	default:
+		result = compiler.NewInteger(a.AsInteger() >> n)
+	}
+	vm.pop()
+	vm.pop()
+	vm.push(result)
 	return nil
 }
 
-func (vm *vm) comparison(f func(x, y float64) bool) error {
-	if !vm.peek(0).IsNumber() || !vm.peek(1).IsNumber() {
-		return &Error{ErrNumberOperands}
+func abs(n int64) int64 {
+	if n < 0 {
+		return -n
 	}
-	b := vm.pop().AsNumber()
-	a := vm.pop().AsNumber()
-	vm.push(compiler.NewBoolean(f(a, b)))
+	return n
+}
+
+func (vm *vm) comparison(f func(c int) bool) error {
+	b := vm.peek(0)
+	a := vm.peek(1)
+	if !a.IsNumber() || !b.IsNumber() {
+		return vm.newError(ErrNumberOperands)
+	}
+	c, ok := compiler.Compare(a, b)
+	vm.pop()
+	vm.pop()
+	vm.push(compiler.NewBoolean(ok && f(c)))
+	return nil
+}
+
+// isZeroDivision reports whether the two numbers on top of the stack are
+// exact and the divisor is zero.
+func (vm *vm) isZeroDivision() bool {
+	return vm.peek(1).IsExact() && isZero(vm.peek(0))
+}
+
+func (vm *vm) call() error {
+	argc := int(vm.readSlot())
+	callee := vm.peek(argc)
+	if !callee.IsNative() {
+		return vm.newError(ErrNotCallable)
+	}
+	native := callee.AsNative()
+	if argc < native.MinArgs || argc > native.MaxArgs {
+		return vm.newError(ErrArity)
+	}
+	args := make([]compiler.Value, argc)
+	for i := range args {
+		args[i] = vm.peek(argc - 1 - i)
+	}
+	result, err := native.Call(args)
+	if err != nil {
+		return &Error{ErrNative, vm.chunk.Lines[vm.i], fmt.Errorf("%s: %w", native.Name, err)}
+	}
+	for i := 0; i <= argc; i++ {
+		vm.pop()
+	}
+	vm.push(result)
 	return nil
 }
 
@@ -87,20 +385,21 @@ func (vm *vm) readJump() int {
 	return int(uint16(vm.chunk.Code[vm.i+1])<<8 | uint16(vm.chunk.Code[vm.i+2]))
 }
 
-func (vm *vm) debug() {
+func (vm *vm) trace(VM) error {
 	var sb strings.Builder
 	o := vm.readOperation()
 	sb.WriteString(fmt.Sprintf("%04d | %-16s |", vm.i, o))
 	switch o {
-	case compiler.OperationJump, compiler.OperationJumpIfFalse, compiler.OperationLoop:
+	case compiler.OperationJump, compiler.OperationJumpIfFalse, compiler.OperationJumpIfNotNil, compiler.OperationLoop:
 		sb.WriteString(fmt.Sprintf(" %d", vm.readJump()))
-	case compiler.OperationGetLocal, compiler.OperationSetLocal:
+	case compiler.OperationGetLocal, compiler.OperationSetLocal, compiler.OperationCall, compiler.OperationSwitch, compiler.OperationMatchList:
 		sb.WriteString(fmt.Sprintf(" %d", vm.readSlot()))
-	case compiler.OperationConstant, compiler.OperationDefineGlobal, compiler.OperationGetGlobal, compiler.OperationSetGlobal:
+	case compiler.OperationConstant, compiler.OperationDefineGlobal, compiler.OperationGetGlobal, compiler.OperationSetGlobal, compiler.OperationGetProperty, compiler.OperationMatchField:
 		sb.WriteString(fmt.Sprintf(" %s", vm.readConstant()))
 	}
 	sb.WriteRune('\n')
 	vm.logger.Print(sb.String())
+	return nil
 }
 
 func (vm *vm) execute() error {
@@ -112,8 +411,24 @@ func (vm *vm) execute() error {
 		if vm.peek(0).IsFalsey() {
 			vm.i += vm.readJump()
 		}
+	case compiler.OperationJumpIfNotNil:
+		if !vm.peek(0).IsNil() {
+			vm.i += vm.readJump()
+		}
 	case compiler.OperationLoop:
 		vm.i -= vm.readJump()
+	case compiler.OperationSwitch:
+		vm.i += vm.chunk.Tables[vm.readSlot()].Jump(vm.pop())
+	case compiler.OperationMatchList:
+		v := vm.pop()
+		vm.push(compiler.NewBoolean(v.IsList() && len(v.AsList()) == int(vm.readSlot())))
+	case compiler.OperationMatchObject:
+		vm.push(compiler.NewBoolean(vm.pop().IsObject()))
+	case compiler.OperationMatchField:
+		_, ok := vm.pop().AsObject()[vm.readConstant().AsString()]
+		vm.push(compiler.NewBoolean(ok))
+	case compiler.OperationNoMatch:
+		return vm.newError(ErrNoMatch)
 	case compiler.OperationGetLocal:
 		vm.push(vm.stack[vm.readSlot()])
 	case compiler.OperationSetLocal:
@@ -122,60 +437,150 @@ func (vm *vm) execute() error {
 		constant := vm.readConstant()
 		_, ok := vm.globals[constant.AsString()]
 		if !ok {
-			return &Error{ErrUndefinedVar}
+			return vm.newError(ErrUndefinedVar)
 		}
 		vm.globals[constant.AsString()] = vm.peek(0)
 	case compiler.OperationGetGlobal:
-		value, ok := vm.globals[vm.readConstant().AsString()]
+		name := vm.readConstant().AsString()
+		value, ok := vm.globals[name]
 		if !ok {
-			return &Error{ErrUndefinedVar}
+			native, ok := compiler.LookupNative(name)
+			if !ok {
+				return vm.newError(ErrUndefinedVar)
+			}
+			value = compiler.NewNative(native)
 		}
 		vm.push(value)
 	case compiler.OperationDefineGlobal:
-		vm.globals[vm.readConstant().AsString()] = vm.pop()
+		name := vm.readConstant().AsString()
+		if !vm.globalFits(name) {
+			return vm.newError(ErrGlobalLimit)
+		}
+		vm.globals[name] = vm.pop()
 	case compiler.OperationConstant:
 		vm.push(vm.readConstant())
+	case compiler.OperationGetProperty:
+		if err := vm.property(); err != nil {
+			return err
+		}
+	case compiler.OperationCall:
+		if err := vm.call(); err != nil {
+			return err
+		}
+	case compiler.OperationStringify:
+		if !vm.peek(0).IsString() {
+			vm.push(compiler.NewString(vm.pop().String()))
+		}
+	case compiler.OperationGetIndex:
+		if err := vm.index(); err != nil {
+			return err
+		}
 	case compiler.OperationPrint:
-		fmt.Println(vm.pop())
+		if err := vm.print(vm.pop()); err != nil {
+			return err
+		}
 	case compiler.OperationPop:
 		vm.pop()
 	case compiler.OperationReturn:
 		vm.isEnd = true
 	case compiler.OperationNegate:
-		if !vm.peek(0).IsNumber() {
-			return &Error{ErrNumberOperand}
+		a := vm.peek(0)
+		if a.IsInteger() && a.AsInteger() == math.MinInt64 {
+			return vm.newError(ErrIntegerOverflow)
 		}
-		vm.push(compiler.NewNumber(-vm.pop().AsNumber()))
+		n, ok := compiler.Negate(a)
+		if !ok {
+			return vm.newError(ErrNumberOperand)
+		}
+		vm.pop()
+		vm.push(n)
 	case compiler.OperationAdd:
 		b := vm.peek(0)
 		a := vm.peek(1)
 		areStrings := a.IsString() && b.IsString()
 		areNumbers := a.IsNumber() && b.IsNumber()
 		if !areStrings && !areNumbers {
-			return &Error{ErrNumberOrStringOperands}
+			return vm.newError(ErrNumberOrStringOperands)
+		}
+		if areStrings && vm.limits.StringLength > 0 && len(a.AsString())+len(b.AsString()) > vm.limits.StringLength {
+			return vm.newError(ErrStringLimit)
+		}
+		if areNumbers {
+			if err := vm.binary(addition); err != nil {
+				return err
+			}
+			break
 		}
 		b = vm.pop()
 		a = vm.pop()
-		if areStrings {
-			var sb strings.Builder
-			sb.WriteString(a.AsString())
-			sb.WriteString(b.AsString())
-			vm.push(compiler.NewString(sb.String()))
-		} else {
-			vm.push(compiler.NewNumber(a.AsNumber() + b.AsNumber()))
-		}
+		var sb strings.Builder
+		sb.WriteString(a.AsString())
+		sb.WriteString(b.AsString())
+		vm.push(compiler.NewString(sb.String()))
 	case compiler.OperationSubtract:
-		if err := vm.binary(func(x, y float64) float64 { return x - y }); err != nil {
+		if err := vm.binary(subtraction); err != nil {
 			return err
 		}
 	case compiler.OperationMultiply:
-		if err := vm.binary(func(x, y float64) float64 { return x * y }); err != nil {
+		if err := vm.binary(multiplication); err != nil {
 			return err
 		}
 	case compiler.OperationDivide:
-		if err := vm.binary(func(x, y float64) float64 { return x / y }); err != nil {
+		if vm.isZeroDivision() {
+			return vm.newError(ErrDivisionByZero)
+		}
+		if err := vm.binary(division); err != nil {
+			return err
+		}
+	case compiler.OperationFloorDivide:
+		if vm.isZeroDivision() {
+			return vm.newError(ErrDivisionByZero)
+		}
+		if err := vm.binary(floorDivision); err != nil {
+			return err
+		}
+	case compiler.OperationModulo:
+		if vm.isZeroDivision() {
+			return vm.newError(ErrDivisionByZero)
+		}
+		if err := vm.binary(modulo); err != nil {
+			return err
+		}
+	case compiler.OperationPower:
+		if err := vm.power(); err != nil {
+			return err
+		}
+	case compiler.OperationBitAnd:
+		if err := vm.bitwise(bitAnd); err != nil {
+			return err
+		}
+	case compiler.OperationBitOr:
+		if err := vm.bitwise(bitOr); err != nil {
+			return err
+		}
+	case compiler.OperationBitXor:
+		if err := vm.bitwise(bitXor); err != nil {
+			return err
+		}
+	case compiler.OperationShiftLeft:
+		if err := vm.shift(true); err != nil {
+			return err
+		}
+	case compiler.OperationShiftRight:
+		if err := vm.shift(false); err != nil {
 			return err
 		}
+	case compiler.OperationBitNot:
+		a, ok := integral(vm.peek(0))
+		if !ok {
+			return vm.newError(ErrIntegerOperand)
+		}
+		vm.pop()
+		if a.IsInteger() {
+			vm.push(compiler.NewInteger(^a.AsInteger()))
+		} else {
+			vm.push(compiler.NewBigInteger(new(big.Int).Not(a.AsBigInteger())))
+		}
 	case compiler.OperationNil:
 		vm.push(compiler.NewNil())
 	case compiler.OperationFalse:
@@ -187,34 +592,135 @@ func (vm *vm) execute() error {
 	case compiler.OperationEqual:
 		b := vm.pop()
 		a := vm.pop()
-		vm.push(compiler.NewBoolean(a == b))
+		vm.push(compiler.NewBoolean(a.Equal(b)))
 	case compiler.OperationGreater:
-		if err := vm.comparison(func(x, y float64) bool { return x > y }); err != nil {
+		if err := vm.comparison(func(c int) bool { return c > 0 }); err != nil {
 			return err
 		}
 	case compiler.OperationLess:
-		if err := vm.comparison(func(x, y float64) bool { return x < y }); err != nil {
+		if err := vm.comparison(func(c int) bool { return c < 0 }); err != nil {
 			return err
 		}
 	}
-	switch o {
-	case compiler.OperationJump, compiler.OperationJumpIfFalse, compiler.OperationLoop:
-		vm.i += 3
-	case compiler.OperationGetLocal, compiler.OperationSetLocal, compiler.OperationConstant, compiler.OperationDefineGlobal, compiler.OperationGetGlobal, compiler.OperationSetGlobal:
-		vm.i += 2
+	vm.i += o.Size()
+	return nil
+}
+
+// property reads a field of an object or the length of a list or string,
+// strings are measured in code points.
+func (vm *vm) property() error {
+	name := vm.readConstant().AsString()
+	target := vm.peek(0)
+	var value compiler.Value
+	switch {
+	case target.IsObject():
+		var ok bool
+		value, ok = target.AsObject()[name]
+		if !ok {
+			return vm.newError(ErrUndefinedProperty)
+		}
+	case target.IsList() || target.IsString():
+		if name != "length" {
+			return vm.newError(ErrUndefinedProperty)
+		}
+		if target.IsList() {
+			value = compiler.NewInteger(int64(len(target.AsList())))
+		} else {
+			value = compiler.NewInteger(int64(utf8.RuneCountInString(target.AsString())))
+		}
 	default:
-		vm.i++
+		return vm.newError(ErrObjectOperand)
 	}
+	vm.pop()
+	vm.push(value)
 	return nil
 }
 
-func (vm *vm) Run() error {
+func (vm *vm) position(index compiler.Value, length int) (int, error) {
+	if !index.IsNumber() || index.AsNumber() != math.Trunc(index.AsNumber()) {
+		return 0, vm.newError(ErrIndexOperand)
+	}
+	i := index.AsNumber()
+	if i < 0 || i >= float64(length) {
+		return 0, vm.newError(ErrIndexRange)
+	}
+	return int(i), nil
+}
+
+// index reads an element of a list, a field of an object or a character of
+// a string, strings are indexed by code points.
+func (vm *vm) index() error {
+	index := vm.peek(0)
+	target := vm.peek(1)
+	var value compiler.Value
+	switch {
+	case target.IsList():
+		items := target.AsList()
+		i, err := vm.position(index, len(items))
+		if err != nil {
+			return err
+		}
+		value = items[i]
+	case target.IsString():
+		runes := []rune(target.AsString())
+		i, err := vm.position(index, len(runes))
+		if err != nil {
+			return err
+		}
+		value = compiler.NewString(string(runes[i]))
+	case target.IsObject():
+		if !index.IsString() {
+			return vm.newError(ErrIndexOperand)
+		}
+		var ok bool
+		value, ok = target.AsObject()[index.AsString()]
+		if !ok {
+			return vm.newError(ErrUndefinedProperty)
+		}
+	default:
+		return vm.newError(ErrIndexable)
+	}
+	vm.pop()
+	vm.pop()
+	vm.push(value)
+	return nil
+}
+
+const cancelInterval = 1024
+
+func (vm *vm) RunContext(ctx context.Context) error {
 	var err error
 	for !vm.isEnd {
-		vm.debug()
+		if vm.executed%cancelInterval == 0 {
+			if err = ctx.Err(); err != nil {
+				return &Error{ErrCanceled, vm.chunk.Lines[vm.i], err}
+			}
+		}
+		if err = vm.checkInstructions(); err != nil {
+			return err
+		}
+		for _, h := range vm.hooks {
+			if err = h(vm); err != nil {
+				return err
+			}
+		}
+		vm.executed++
+		offset := vm.i
 		if err = vm.execute(); err != nil {
 			return err
 		}
+		if err = vm.checkStack(offset); err != nil {
+			return err
+		}
+		for _, h := range vm.afterHooks {
+			if err = h(vm); err != nil {
+				return err
+			}
+		}
 	}
 	return nil
 }
+
+func (vm *vm) Run() error {
+	return vm.RunContext(context.Background())
+}
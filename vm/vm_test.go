@@ -0,0 +1,95 @@
+package vm
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lukibw/abc/compiler"
+	"github.com/lukibw/abc/scanner"
+)
+
+func TestRunContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	timeout, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	tests := []struct {
+		name string
+		ctx  context.Context
+		want error
+		line int
+	}{
+		{"canceled before start", canceled, context.Canceled, 1},
+		{"timeout in loop", timeout, context.DeadlineExceeded, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := newVM(t, "var a = 1;\nprint a;\nwhile (true) {}\n", WithoutPrint()).RunContext(tt.ctx)
+			var e *Error
+			if !errors.As(err, &e) || e.Kind != ErrCanceled {
+				t.Fatalf("RunContext() error = %v, want %v", err, ErrCanceled)
+			}
+			if !errors.Is(err, tt.want) || !errors.Is(err, tt.ctx.Err()) {
+				t.Fatalf("RunContext() error = %v, want it to wrap %v", err, tt.want)
+			}
+			if e.Line != tt.line {
+				t.Fatalf("RunContext() error line = %d, want %d", e.Line, tt.line)
+			}
+		})
+	}
+}
+
+func newVM(t *testing.T, source string, options ...Option) VM {
+	t.Helper()
+	v, err := New(compiler.New(scanner.New([]byte(source))), options...)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	return v
+}
+
+func TestWithOutput(t *testing.T) {
+	var output strings.Builder
+	if err := newVM(t, `print 1; print "a${2}"; print nil;`, WithOutput(&output)).Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if got, want := output.String(), "1\na2\nnil\n"; got != want {
+		t.Fatalf("output = %q, want %q", got, want)
+	}
+}
+
+func TestWithPrint(t *testing.T) {
+	values := make([]compiler.Value, 0)
+	print := func(v compiler.Value) error {
+		values = append(values, v)
+		return nil
+	}
+	if err := newVM(t, `print 1; print "a"; print true;`, WithPrint(print)).Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if len(values) != 3 || values[0].AsInteger() != 1 || values[1].AsString() != "a" || !values[2].AsBoolean() {
+		t.Fatalf("printed values = %v", values)
+	}
+	failure := errors.New("closed")
+	err := newVM(t, "print 1;", WithPrint(func(compiler.Value) error { return failure })).Run()
+	if !errors.Is(err, failure) {
+		t.Fatalf("Run() error = %v, want %v", err, failure)
+	}
+}
+
+func TestWithoutPrint(t *testing.T) {
+	var output strings.Builder
+	v := newVM(t, "print 1; var a = 2; print a;", WithOutput(&output), WithoutPrint())
+	if err := v.Run(); err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if output.Len() != 0 {
+		t.Fatalf("output = %q, want none", output.String())
+	}
+	if a, ok := v.Global("a"); !ok || a.AsInteger() != 2 {
+		t.Fatalf("Global(a) = %v, %v, want the script to run", a, ok)
+	}
+}